@@ -0,0 +1,393 @@
+package ecs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StorageKind is a storage backend of component data.
+type StorageKind int
+
+const (
+	// StorageSorted stores the data of each component type in a slice sorted
+	// by entity. Views join the slices of their components (merge join).
+	StorageSorted StorageKind = iota
+	// StorageArchetype stores the entities that have the same set of
+	// components in a table (archetype), where each component is a column.
+	// Iterating a view only visits the tables that match the view, at the
+	// cost of moving the entity between tables when a component is added or
+	// removed. Views iterate the entities grouped by table (not sorted), and
+	// components must not be added or removed while iterating.
+	StorageArchetype
+	// StorageSparse stores the data in a dense slice indexed by a sparse
+	// (paged) entity index. Adding and removing components is O(1), which
+	// suits components that are toggled often. Views iterate the data in
+	// dense (not sorted) order, and the data must not be removed while
+	// iterating. See SetComponentStorage.
+	StorageSparse
+)
+
+// WorldOptions are the options of NewWorldWithOptions.
+type WorldOptions struct {
+	// Storage is the default storage backend of the component stores of the
+	// world. It can be overridden per component type with
+	// SetComponentStorage.
+	Storage StorageKind
+	// Empty creates the world without the global systems (see NewEmptyWorld).
+	Empty bool
+}
+
+// componentBackend is an alternative storage of the component data of a
+// ComponentStore. The default storage (a sorted slice) is implemented by the
+// ComponentStore itself.
+type componentBackend[T ComponentType] interface {
+	// get returns a pointer to the data of e (or nil)
+	get(e Entity) *T
+	// insert adds the data of e. The entity must not have the component.
+	insert(e Entity, data T)
+	remove(e Entity) bool
+	// removeMany removes the data of the entities (sorted, and all with the
+	// component).
+	removeMany(ents []Entity)
+	len() int
+	// sorted returns a copy of all the component data, sorted by entity
+	sorted() []ComponentData[T]
+	each(fn func(e Entity, d *T))
+	// snapshot copies the state of the backend (reusing dst, a previous
+	// snapshot, if possible)
+	snapshot(dst interface{}) interface{}
+	// restore restores a snapshot (or clears the backend if s is nil)
+	restore(s interface{})
+}
+
+// archetypeColumn is a column (component data) of an archetype table.
+type archetypeColumn interface {
+	// moveRow appends the data at row to dst (a column of the same type).
+	moveRow(row int, dst archetypeColumn)
+	// swapRemove removes the data at row by moving the last row into it.
+	swapRemove(row int)
+	newColumn() archetypeColumn
+	// copyTo copies the data to dst (a column of the same type, or nil) and
+	// returns dst.
+	copyTo(dst archetypeColumn) archetypeColumn
+	reset()
+}
+
+type archetypeColumnData[T ComponentType] struct {
+	data []T
+}
+
+func (c *archetypeColumnData[T]) moveRow(row int, dst archetypeColumn) {
+	d := dst.(*archetypeColumnData[T])
+	d.data = append(d.data, c.data[row])
+}
+
+func (c *archetypeColumnData[T]) swapRemove(row int) {
+	last := len(c.data) - 1
+	c.data[row] = c.data[last]
+	var zv T
+	c.data[last] = zv
+	c.data = c.data[:last]
+}
+
+func (c *archetypeColumnData[T]) newColumn() archetypeColumn {
+	return &archetypeColumnData[T]{
+		data: make([]T, 0, 64),
+	}
+}
+
+// archetype is a table of all the entities that have the same set of
+// components.
+type archetype struct {
+	types    []int // sorted component ids
+	entities []Entity
+	columns  map[int]archetypeColumn
+	// transitions (cache)
+	add    map[int]*archetype
+	remove map[int]*archetype
+}
+
+func (a *archetype) has(cid int) bool {
+	_, ok := a.columns[cid]
+	return ok
+}
+
+type archetypeLocation struct {
+	arch *archetype
+	row  int
+}
+
+// archetypeStorage holds all the archetype tables of a world.
+type archetypeStorage struct {
+	root       *archetype
+	archetypes []*archetype
+	byKey      map[string]*archetype
+	locations  map[Entity]archetypeLocation
+	columns    []archetypeColumn // column prototypes by component id
+}
+
+func newArchetypeStorage() *archetypeStorage {
+	root := newArchetype(nil)
+	return &archetypeStorage{
+		root:       root,
+		archetypes: []*archetype{root},
+		byKey: map[string]*archetype{
+			"": root,
+		},
+		locations: make(map[Entity]archetypeLocation),
+		columns:   make([]archetypeColumn, 0, 32),
+	}
+}
+
+func newArchetype(types []int) *archetype {
+	return &archetype{
+		types:    types,
+		entities: make([]Entity, 0, 64),
+		columns:  make(map[int]archetypeColumn, len(types)),
+		add:      make(map[int]*archetype),
+		remove:   make(map[int]*archetype),
+	}
+}
+
+// registerColumn returns the component id of a new column type
+func (s *archetypeStorage) registerColumn(proto archetypeColumn) int {
+	s.columns = append(s.columns, proto)
+	return len(s.columns) - 1
+}
+
+func (s *archetypeStorage) getArchetype(types []int) *archetype {
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = strconv.Itoa(t)
+	}
+	key := strings.Join(parts, ",")
+	if a, ok := s.byKey[key]; ok {
+		return a
+	}
+	a := newArchetype(types)
+	for _, t := range types {
+		a.columns[t] = s.columns[t].newColumn()
+	}
+	s.byKey[key] = a
+	s.archetypes = append(s.archetypes, a)
+	return a
+}
+
+func (s *archetypeStorage) with(a *archetype, cid int) *archetype {
+	if next, ok := a.add[cid]; ok {
+		return next
+	}
+	types := make([]int, 0, len(a.types)+1)
+	index := sort.SearchInts(a.types, cid)
+	types = append(types, a.types[:index]...)
+	types = append(types, cid)
+	types = append(types, a.types[index:]...)
+	next := s.getArchetype(types)
+	a.add[cid] = next
+	next.remove[cid] = a
+	return next
+}
+
+func (s *archetypeStorage) without(a *archetype, cid int) *archetype {
+	if next, ok := a.remove[cid]; ok {
+		return next
+	}
+	types := make([]int, 0, len(a.types))
+	for _, t := range a.types {
+		if t != cid {
+			types = append(types, t)
+		}
+	}
+	next := s.getArchetype(types)
+	a.remove[cid] = next
+	next.add[cid] = a
+	return next
+}
+
+// move moves the entity e (and its data) from its current archetype to dst.
+// The columns that dst doesn't have are dropped. It returns the row of e in
+// dst.
+func (s *archetypeStorage) move(e Entity, dst *archetype) int {
+	loc, ok := s.locations[e]
+	if !ok {
+		loc = archetypeLocation{arch: s.root}
+	} else {
+		src := loc.arch
+		for cid, col := range src.columns {
+			if dcol, ok := dst.columns[cid]; ok {
+				col.moveRow(loc.row, dcol)
+			}
+			col.swapRemove(loc.row)
+		}
+		last := len(src.entities) - 1
+		if loc.row != last {
+			moved := src.entities[last]
+			src.entities[loc.row] = moved
+			s.locations[moved] = archetypeLocation{arch: src, row: loc.row}
+		}
+		src.entities = src.entities[:last]
+	}
+	if dst == s.root {
+		delete(s.locations, e)
+		return -1
+	}
+	dst.entities = append(dst.entities, e)
+	row := len(dst.entities) - 1
+	s.locations[e] = archetypeLocation{arch: dst, row: row}
+	return row
+}
+
+// drop removes the entities (and all their data) from the tables. Each entity
+// is moved only once, instead of once per component.
+func (s *archetypeStorage) drop(ents []Entity) {
+	for _, e := range ents {
+		if _, ok := s.locations[e]; ok {
+			s.move(e, s.root)
+		}
+	}
+}
+
+// matching returns the archetypes that have all the component ids
+func (s *archetypeStorage) matching(cids ...int) []*archetype {
+	result := make([]*archetype, 0, 8)
+	for _, a := range s.archetypes {
+		ok := true
+		for _, cid := range cids {
+			if !a.has(cid) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// archetypeBackend is the componentBackend of StorageArchetype
+type archetypeBackend[T ComponentType] struct {
+	storage *archetypeStorage
+	cid     int
+	count   int
+}
+
+func newArchetypeBackend[T ComponentType](s *archetypeStorage) *archetypeBackend[T] {
+	return &archetypeBackend[T]{
+		storage: s,
+		cid:     s.registerColumn(&archetypeColumnData[T]{}),
+	}
+}
+
+func (b *archetypeBackend[T]) get(e Entity) *T {
+	loc, ok := b.storage.locations[e]
+	if !ok {
+		return nil
+	}
+	col, ok := loc.arch.columns[b.cid]
+	if !ok {
+		return nil
+	}
+	return &col.(*archetypeColumnData[T]).data[loc.row]
+}
+
+func (b *archetypeBackend[T]) insert(e Entity, data T) {
+	src := b.storage.root
+	if loc, ok := b.storage.locations[e]; ok {
+		src = loc.arch
+	}
+	dst := b.storage.with(src, b.cid)
+	b.storage.move(e, dst)
+	col := dst.columns[b.cid].(*archetypeColumnData[T])
+	col.data = append(col.data, data)
+	b.count++
+}
+
+func (b *archetypeBackend[T]) remove(e Entity) bool {
+	loc, ok := b.storage.locations[e]
+	if !ok || !loc.arch.has(b.cid) {
+		return false
+	}
+	b.storage.move(e, b.storage.without(loc.arch, b.cid))
+	b.count--
+	return true
+}
+
+// removeMany removes the component of the entities. The entities that were
+// dropped from the storage (see archetypeStorage.drop), which is how the world
+// removes entities in batch, are only counted.
+func (b *archetypeBackend[T]) removeMany(ents []Entity) {
+	for _, e := range ents {
+		if loc, ok := b.storage.locations[e]; ok && loc.arch.has(b.cid) {
+			b.storage.move(e, b.storage.without(loc.arch, b.cid))
+		}
+	}
+	b.count -= len(ents)
+}
+
+func (b *archetypeBackend[T]) len() int {
+	return b.count
+}
+
+func (b *archetypeBackend[T]) sorted() []ComponentData[T] {
+	result := make([]ComponentData[T], 0, b.count)
+	b.each(func(e Entity, d *T) {
+		result = append(result, ComponentData[T]{
+			Entity: e,
+			Data:   *d,
+		})
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Entity < result[j].Entity
+	})
+	return result
+}
+
+func (b *archetypeBackend[T]) each(fn func(e Entity, d *T)) {
+	for _, a := range b.storage.matching(b.cid) {
+		col := archetypeColumnOf[T](a, b.cid)
+		for i := range a.entities {
+			fn(a.entities[i], &col[i])
+		}
+	}
+}
+
+// archetypeIDs returns the component ids of the stores if the world uses the
+// archetype storage.
+func archetypeIDs(stores ...interface{ archetypeID() (int, bool) }) ([]int, bool) {
+	ids := make([]int, len(stores))
+	for i, s := range stores {
+		id, ok := s.archetypeID()
+		if !ok {
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
+
+func (c *ComponentStore[T]) archetypeID() (int, bool) {
+	if b, ok := c.backend.(*archetypeBackend[T]); ok {
+		return b.cid, true
+	}
+	return 0, false
+}
+
+func archetypeColumnOf[T ComponentType](a *archetype, cid int) []T {
+	return a.columns[cid].(*archetypeColumnData[T]).data
+}
+
+// NewWorldWithOptions creates a new world with custom options (see
+// WorldOptions).
+func NewWorldWithOptions(opts WorldOptions) *World {
+	w := newWorld()
+	w.storage = opts.Storage
+	if opts.Storage == StorageArchetype {
+		w.archetypes = newArchetypeStorage()
+	}
+	if !opts.Empty {
+		addGlobalSystems(w)
+	}
+	return w
+}
@@ -0,0 +1,178 @@
+package ecs
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func populateStorageTestWorld(w *World, n int) []Entity {
+	ents := make([]Entity, 0, n)
+	for i := 0; i < n; i++ {
+		e := w.NewEntity()
+		ents = append(ents, e)
+		Set(w, e, BenchPos3{X: float64(i)})
+		if i%2 == 0 {
+			Set(w, e, BenchSpeed3{Xs: 1})
+		}
+		if i%3 == 0 {
+			Set(w, e, BenchAccel{Xa: 1})
+		}
+		if i%5 == 0 {
+			Set(w, e, BenchDeltaSpeed{Delta: 1})
+		}
+	}
+	return ents
+}
+
+func TestArchetypeStorage(t *testing.T) {
+	w := NewWorldWithOptions(WorldOptions{
+		Storage: StorageArchetype,
+		Empty:   true,
+	})
+	sorted := NewEmptyWorld()
+	ents := populateStorageTestWorld(w, 100)
+	populateStorageTestWorld(sorted, 100)
+
+	collect := func(w *World) []Entity {
+		result := make([]Entity, 0)
+		view := NewView4[BenchPos3, BenchSpeed3, BenchAccel, BenchDeltaSpeed](w, nil, nil)
+		defer view.Destroy()
+		view.Each(func(e Entity, p *BenchPos3, s *BenchSpeed3, a *BenchAccel, d *BenchDeltaSpeed) {
+			p.X += s.Xs + a.Xa + d.Delta
+			result = append(result, e)
+		})
+		sort.Slice(result, func(i, j int) bool {
+			return result[i] < result[j]
+		})
+		return result
+	}
+	result := collect(w)
+	assert.Equal(t, collect(sorted), result)
+	assert.Equal(t, 4, len(result))
+
+	assert.True(t, Apply(w, ents[30], func(p *BenchPos3) {
+		assert.Equal(t, 33.0, p.X)
+	}))
+	assert.Equal(t, 100, GetComponentStore[BenchPos3](w).Len())
+	assert.Equal(t, 50, GetComponentStore[BenchSpeed3](w).Len())
+
+	// moving between archetypes keeps the data
+	assert.True(t, RemoveComponent[BenchSpeed3](w, ents[30]))
+	assert.False(t, Contains[BenchSpeed3](w, ents[30]))
+	assert.True(t, Contains[BenchAccel](w, ents[30]))
+	Set(w, ents[30], BenchSpeed3{Xs: 7})
+	assert.True(t, Apply(w, ents[30], func(s *BenchSpeed3) {
+		assert.Equal(t, 7.0, s.Xs)
+	}))
+	assert.True(t, Apply(w, ents[30], func(d *BenchDeltaSpeed) {
+		assert.Equal(t, 1.0, d.Delta)
+	}))
+
+	view := NewView2[BenchPos3, BenchAccel](w, nil, nil)
+	assert.Equal(t, 34, view.Len())
+	assert.True(t, w.Remove(ents[0]))
+	assert.Equal(t, 33, view.Len())
+	n := 0
+	view.Each(func(e Entity, p *BenchPos3, a *BenchAccel) {
+		n++
+	})
+	assert.Equal(t, 33, n)
+	all := GetComponentStore[BenchPos3](w).all()
+	assert.Equal(t, 99, len(all))
+	assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool {
+		return all[i].Entity < all[j].Entity
+	}))
+}
+
+func benchmarkView4(b *testing.B, opts WorldOptions) {
+	b.StopTimer()
+	w := NewWorldWithOptions(opts)
+	for i := 0; i < 10000; i++ {
+		e := w.NewEntity()
+		Set(w, e, BenchPos3{X: rand.Float64()})
+		if i%2 == 0 {
+			Set(w, e, BenchSpeed3{Xs: rand.Float64()})
+		}
+		if i%3 == 0 {
+			Set(w, e, BenchAccel{Xa: rand.Float64()})
+		}
+		if i%4 == 0 {
+			Set(w, e, BenchDeltaSpeed{Delta: rand.Float64()})
+		}
+	}
+	view := NewView4[BenchPos3, BenchSpeed3, BenchAccel, BenchDeltaSpeed](w, nil, nil)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		view.Each(func(e Entity, p *BenchPos3, s *BenchSpeed3, a *BenchAccel, d *BenchDeltaSpeed) {
+			s.Xs += a.Xa * d.Delta
+			p.X += s.Xs
+		})
+	}
+}
+
+func BenchmarkView4Sorted(b *testing.B) {
+	benchmarkView4(b, WorldOptions{Storage: StorageSorted, Empty: true})
+}
+
+func BenchmarkView4Archetype(b *testing.B) {
+	benchmarkView4(b, WorldOptions{Storage: StorageArchetype, Empty: true})
+}
+
+func TestArchetypeRemoveEntity(t *testing.T) {
+	w := NewWorldWithOptions(WorldOptions{
+		Storage: StorageArchetype,
+		Empty:   true,
+	})
+	removed := 0
+	view := NewView2[BenchPos3, BenchSpeed3](w, nil, func(e Entity) {
+		removed++
+	})
+	defer view.Destroy()
+	e1 := w.NewEntity()
+	e2 := w.NewEntity()
+	for _, e := range []Entity{e1, e2} {
+		Set(w, e, BenchPos3{X: float64(e)})
+		Set(w, e, BenchSpeed3{Xs: 1})
+		Set(w, e, BenchAccel{Xa: 1})
+	}
+	n := len(w.archetypes.archetypes)
+	assert.True(t, w.Remove(e1))
+	// no intermediate archetypes
+	assert.Equal(t, n, len(w.archetypes.archetypes))
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, 1, GetComponentStore[BenchPos3](w).Len())
+	assert.Equal(t, 1, GetComponentStore[BenchAccel](w).Len())
+	assert.False(t, Contains[BenchSpeed3](w, e1))
+	assert.True(t, Apply(w, e2, func(p *BenchPos3) {
+		assert.Equal(t, float64(e2), p.X)
+	}))
+}
+
+func TestArchetypeViewEach(t *testing.T) {
+	w := NewWorldWithOptions(WorldOptions{
+		Storage: StorageArchetype,
+		Empty:   true,
+	})
+	view := NewView[BenchPos3](w, nil, nil)
+	defer view.Destroy()
+	for i := 0; i < 10; i++ {
+		e := w.NewEntity()
+		Set(w, e, BenchPos3{X: float64(i)})
+		if i%2 == 0 {
+			Set(w, e, BenchSpeed3{})
+		}
+	}
+	sum := 0.0
+	fn := func(e Entity, p *BenchPos3) {
+		sum += p.X
+	}
+	view.Each(fn)
+	assert.Equal(t, 45.0, sum)
+	// the matched archetypes are cached by the view
+	assert.Equal(t, 0.0, testing.AllocsPerRun(10, func() {
+		view.Each(fn)
+	}))
+}
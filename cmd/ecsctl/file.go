@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gabstv/ecs/v3"
+	"github.com/google/uuid"
+)
+
+type format string
+
+const (
+	formatTOML format = "toml"
+	formatJSON format = "json"
+)
+
+// saveFile is the raw form of a world file (see ecs.SerializedWorld).
+type saveFile struct {
+	Entities       []saveEntity       `toml:"entities" json:"entities"`
+	ComponentIndex ecs.ComponentIndex `toml:"component_index" json:"component_index"`
+	Relations      []saveRelation     `toml:"relations,omitempty" json:"relations,omitempty"`
+	Enabled        bool               `toml:"enabled" json:"enabled"`
+}
+
+type saveEntity struct {
+	UUID       uuid.UUID       `toml:"uuid" json:"uuid"`
+	Name       string          `toml:"name,omitempty" json:"name,omitempty"`
+	Components []saveComponent `toml:"components" json:"components"`
+}
+
+type saveComponent struct {
+	CI   int         `toml:"ci" json:"ci"`
+	Data interface{} `toml:"data" json:"data"`
+}
+
+type saveRelation struct {
+	Relation string      `toml:"relation" json:"relation"`
+	Source   uuid.UUID   `toml:"source" json:"source"`
+	Target   uuid.UUID   `toml:"target" json:"target"`
+	Data     interface{} `toml:"data" json:"data"`
+}
+
+func loadFile(path string) (*saveFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFile(data, detectFormat(path, data))
+}
+
+func decodeFile(data []byte, f format) (*saveFile, error) {
+	sf := &saveFile{}
+	if f == formatJSON {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(sf); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		for i := range sf.Entities {
+			for j := range sf.Entities[i].Components {
+				c := &sf.Entities[i].Components[j]
+				c.Data = jsonNumbers(c.Data)
+			}
+		}
+		for i := range sf.Relations {
+			sf.Relations[i].Data = jsonNumbers(sf.Relations[i].Data)
+		}
+		return sf, nil
+	}
+	if _, err := toml.Decode(string(data), sf); err != nil {
+		return nil, fmt.Errorf("invalid toml: %w", err)
+	}
+	return sf, nil
+}
+
+// jsonNumbers converts the json numbers to int64 (if integral) or float64, to
+// keep the integers of TOML files.
+func jsonNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range x {
+			x[k] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for k, item := range x {
+			x[k] = jsonNumbers(item)
+		}
+	}
+	return v
+}
+
+func detectFormat(path string, data []byte) format {
+	if f := formatOfPath(path); f != "" {
+		return f
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		return formatJSON
+	}
+	return formatTOML
+}
+
+func formatOfPath(path string) format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return formatJSON
+	case ".toml":
+		return formatTOML
+	}
+	return ""
+}
+
+func encodeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func encodeTOML(w io.Writer, v interface{}) error {
+	return toml.NewEncoder(w).Encode(v)
+}
+
+type componentNames map[int]string
+
+func (n componentNames) of(ci int) string {
+	if name, ok := n[ci]; ok {
+		return name
+	}
+	return fmt.Sprintf("[%d]", ci)
+}
+
+func (sf *saveFile) componentNames() componentNames {
+	names := make(componentNames)
+	for _, v := range sf.ComponentIndex {
+		names[v.Index] = v.Name
+	}
+	return names
+}
+
+func (sf *saveFile) sortedIndex() ecs.ComponentIndex {
+	index := make(ecs.ComponentIndex, len(sf.ComponentIndex))
+	copy(index, sf.ComponentIndex)
+	sort.Slice(index, func(i, j int) bool {
+		return index[i].Index < index[j].Index
+	})
+	return index
+}
+
+// findEntity finds an entity by UUID or name
+func (sf *saveFile) findEntity(id string) *saveEntity {
+	u, err := uuid.Parse(id)
+	for i := range sf.Entities {
+		if err == nil && sf.Entities[i].UUID == u {
+			return &sf.Entities[i]
+		}
+		if err != nil && sf.Entities[i].Name == id {
+			return &sf.Entities[i]
+		}
+	}
+	return nil
+}
+
+// serializedWorld returns the file as an ecs.SerializedWorld with raw
+// component data (see ecs.Diff).
+func (sf *saveFile) serializedWorld() ecs.SerializedWorld {
+	sw := ecs.SerializedWorld{
+		Entities:       make([]ecs.SerializedEntity, 0, len(sf.Entities)),
+		ComponentIndex: sf.ComponentIndex,
+		Enabled:        sf.Enabled,
+	}
+	for _, ent := range sf.Entities {
+		se := ecs.SerializedEntity{
+			UUID:       ent.UUID,
+			Name:       ent.Name,
+			Components: make([]interface{}, 0, len(ent.Components)),
+		}
+		for _, c := range ent.Components {
+			se.Components = append(se.Components, ecs.SerializedComponentData{
+				CI:   c.CI,
+				Data: c.Data,
+			})
+		}
+		sw.Entities = append(sw.Entities, se)
+	}
+	for _, r := range sf.Relations {
+		sw.Relations = append(sw.Relations, ecs.SerializedRelation{
+			Relation: r.Relation,
+			Source:   r.Source,
+			Target:   r.Target,
+			Data:     r.Data,
+		})
+	}
+	return sw
+}
+
+// validate returns the structural problems of the file
+func (sf *saveFile) validate() []string {
+	problems := make([]string, 0)
+	names := make(map[string]int)
+	indexes := make(map[int]string)
+	for _, v := range sf.ComponentIndex {
+		if v.Name == "" {
+			problems = append(problems, fmt.Sprintf("component index %d has no name", v.Index))
+		}
+		if prev, ok := names[v.Name]; ok {
+			problems = append(problems, fmt.Sprintf("component %s is indexed twice (%d and %d)", v.Name, prev, v.Index))
+		}
+		if prev, ok := indexes[v.Index]; ok {
+			problems = append(problems, fmt.Sprintf("component index %d is used by %s and %s", v.Index, prev, v.Name))
+		}
+		names[v.Name] = v.Index
+		indexes[v.Index] = v.Name
+	}
+	uuids := make(map[uuid.UUID]bool)
+	for _, ent := range sf.Entities {
+		if ent.UUID == uuid.Nil {
+			problems = append(problems, "entity with a nil UUID")
+		}
+		if uuids[ent.UUID] {
+			problems = append(problems, fmt.Sprintf("entity %s: %v", ent.UUID, ecs.ErrDuplicateUUID))
+		}
+		uuids[ent.UUID] = true
+		seen := make(map[int]bool)
+		for _, c := range ent.Components {
+			if _, ok := indexes[c.CI]; !ok {
+				problems = append(problems, fmt.Sprintf("entity %s: component index %d not found in component_index", ent.UUID, c.CI))
+			}
+			if seen[c.CI] {
+				problems = append(problems, fmt.Sprintf("entity %s: component %s is repeated", ent.UUID, indexes[c.CI]))
+			}
+			seen[c.CI] = true
+			if c.Data == nil {
+				problems = append(problems, fmt.Sprintf("entity %s: component %s has no data", ent.UUID, indexes[c.CI]))
+			}
+		}
+	}
+	for _, r := range sf.Relations {
+		if r.Relation == "" || r.Source == uuid.Nil || r.Target == uuid.Nil {
+			problems = append(problems, fmt.Sprintf("invalid relation %q from %s to %s", r.Relation, r.Source, r.Target))
+		}
+	}
+	return problems
+}
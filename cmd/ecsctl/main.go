@@ -0,0 +1,268 @@
+// Command ecsctl inspects and converts the world files written by
+// World.MarshalTo.
+//
+// Usage:
+//
+//	ecsctl list FILE                        list the entities
+//	ecsctl show FILE UUID|NAME              show the components of an entity
+//	ecsctl components FILE                  show the component index and counts
+//	ecsctl diff FILE1 FILE2                 show the changes from FILE1 to FILE2
+//	ecsctl validate FILE...                 check the structure of the files
+//	ecsctl convert [-to toml|json] IN [OUT] convert a file between formats
+//
+// The component data is handled in its raw form, so the component types don't
+// need to be known by ecsctl.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gabstv/ecs/v3"
+	"github.com/google/uuid"
+)
+
+type command struct {
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"list":       {"list FILE", runList},
+	"show":       {"show [-json] FILE UUID|NAME", runShow},
+	"components": {"components FILE", runComponents},
+	"diff":       {"diff FILE1 FILE2", runDiff},
+	"validate":   {"validate FILE...", runValidate},
+	"convert":    {"convert [-to toml|json] IN [OUT]", runConvert},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdout); err != nil {
+		fmt.Fprintf(stderr, "ecsctl %s: %v\n", args[0], err)
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintf(stderr, "usage: ecsctl %s\n", cmd.usage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: ecsctl COMMAND [ARGS]")
+	for _, name := range []string{"list", "show", "components", "diff", "validate", "convert"} {
+		fmt.Fprintf(w, "  ecsctl %s\n", commands[name].usage)
+	}
+}
+
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func runList(args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return usageError("expected 1 file")
+	}
+	sf, err := loadFile(args[0])
+	if err != nil {
+		return err
+	}
+	names := sf.componentNames()
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "UUID\tNAME\tCOMPONENTS")
+	for _, ent := range sf.Entities {
+		comps := ""
+		for i, c := range ent.Components {
+			if i > 0 {
+				comps += ", "
+			}
+			comps += names.of(c.CI)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", ent.UUID, ent.Name, comps)
+	}
+	return tw.Flush()
+}
+
+func runShow(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() != 2 {
+		return usageError("expected a file and an entity UUID or name")
+	}
+	sf, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ent := sf.findEntity(fs.Arg(1))
+	if ent == nil {
+		return fmt.Errorf("entity %s not found", fs.Arg(1))
+	}
+	names := sf.componentNames()
+	out := entityView{
+		UUID:       ent.UUID,
+		Name:       ent.Name,
+		Components: make(map[string]interface{}),
+	}
+	for _, c := range ent.Components {
+		out.Components[names.of(c.CI)] = c.Data
+	}
+	for _, r := range sf.Relations {
+		if r.Source == ent.UUID || r.Target == ent.UUID {
+			out.Relations = append(out.Relations, r)
+		}
+	}
+	if *asJSON {
+		return encodeJSON(stdout, out)
+	}
+	return encodeTOML(stdout, out)
+}
+
+func runComponents(args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return usageError("expected 1 file")
+	}
+	sf, err := loadFile(args[0])
+	if err != nil {
+		return err
+	}
+	counts := make(map[int]int)
+	for _, ent := range sf.Entities {
+		for _, c := range ent.Components {
+			counts[c.CI]++
+		}
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tNAME\tVERSION\tENTITIES")
+	for _, v := range sf.sortedIndex() {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", v.Index, v.Name, v.Version, counts[v.Index])
+	}
+	return tw.Flush()
+}
+
+func runDiff(args []string, stdout io.Writer) error {
+	if len(args) != 2 {
+		return usageError("expected 2 files")
+	}
+	from, err := loadFile(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := loadFile(args[1])
+	if err != nil {
+		return err
+	}
+	d := ecs.Diff(from.serializedWorld(), to.serializedWorld())
+	if d.IsEmpty() {
+		fmt.Fprintln(stdout, "# no changes")
+		return nil
+	}
+	return d.MarshalTo(stdout)
+}
+
+func runValidate(args []string, stdout io.Writer) error {
+	if len(args) < 1 {
+		return usageError("expected at least 1 file")
+	}
+	invalid := 0
+	for _, path := range args {
+		sf, err := loadFile(path)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: %v\n", path, err)
+			invalid++
+			continue
+		}
+		problems := sf.validate()
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "%s: %s\n", path, p)
+		}
+		if len(problems) > 0 {
+			invalid++
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok (%d entities)\n", path, len(sf.Entities))
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid file(s)", invalid)
+	}
+	return nil
+}
+
+func runConvert(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	to := fs.String("to", "", "output format: toml or json (default: by the OUT extension, or the other format)")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("expected an input file and an optional output file")
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	infmt := detectFormat(fs.Arg(0), data)
+	sf, err := decodeFile(data, infmt)
+	if err != nil {
+		return err
+	}
+	outfmt := format(*to)
+	if outfmt == "" && fs.NArg() == 2 {
+		outfmt = formatOfPath(fs.Arg(1))
+	}
+	if outfmt == "" {
+		outfmt = formatJSON
+		if infmt == formatJSON {
+			outfmt = formatTOML
+		}
+	}
+	if outfmt != formatJSON && outfmt != formatTOML {
+		return usageError(fmt.Sprintf("unknown format %q", outfmt))
+	}
+	encode := encodeTOML
+	if outfmt == formatJSON {
+		encode = encodeJSON
+	}
+	if fs.NArg() == 1 {
+		return encode(stdout, sf)
+	}
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := encode(f, sf); err != nil {
+		_ = f.Close()
+		return err
+	}
+	// a failed close may mean a truncated file
+	return f.Close()
+}
+
+type entityView struct {
+	UUID       uuid.UUID              `toml:"uuid" json:"uuid"`
+	Name       string                 `toml:"name,omitempty" json:"name,omitempty"`
+	Components map[string]interface{} `toml:"components" json:"components"`
+	Relations  []saveRelation         `toml:"relations,omitempty" json:"relations,omitempty"`
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabstv/ecs/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+type position struct {
+	X, Y int
+	Ref  ecs.Entity
+}
+
+func (position) Pkg() string {
+	return "ecsctl.position"
+}
+
+type follows struct{}
+
+func (follows) Pkg() string {
+	return "ecsctl.follows"
+}
+
+func writeWorld(t *testing.T, path string, fn func(w *ecs.World)) *ecs.World {
+	w := ecs.NewEmptyWorld()
+	fn(w)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(buf))
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	return w
+}
+
+func runCmd(args ...string) (int, string) {
+	out := new(bytes.Buffer)
+	code := run(args, out, out)
+	return code, out.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.toml")
+	b := filepath.Join(dir, "b.toml")
+	var e1, e2 ecs.Entity
+	wa := writeWorld(t, a, func(w *ecs.World) {
+		e1 = w.NewEntity()
+		e2 = w.NewEntity()
+		ecs.Set(w, e1, position{X: 1, Y: 2, Ref: e2})
+		ecs.Set(w, e2, position{X: 3})
+		assert.NoError(t, w.SetName(e1, "player"))
+	})
+	id1 := wa.EntityUUID(e1).String()
+	code, out := runCmd("list", a)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, id1)
+	assert.Contains(t, out, "player")
+
+	code, out = runCmd("show", "-json", a, "player")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"X": 1`)
+	assert.Contains(t, out, wa.EntityUUID(e2).String())
+	code, _ = runCmd("show", a, "nobody")
+	assert.Equal(t, 1, code)
+
+	code, out = runCmd("components", a)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "ecsctl.position")
+
+	// b: e1 moved, e2 despawned
+	ecs.Apply(wa, e1, func(p *position) {
+		p.X = 10
+	})
+	wa.Remove(e2)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, wa.MarshalTo(buf))
+	assert.NoError(t, os.WriteFile(b, buf.Bytes(), 0o644))
+	code, out = runCmd("diff", a, b)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "despawned")
+	assert.Contains(t, out, `op = "changed"`)
+	code, out = runCmd("diff", a, a)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "no changes")
+
+	// only the relations changed
+	r := filepath.Join(dir, "r.toml")
+	e3 := wa.NewEntity()
+	assert.NoError(t, ecs.Relate(wa, e1, e3, follows{}))
+	buf.Reset()
+	assert.NoError(t, wa.MarshalTo(buf))
+	assert.NoError(t, os.WriteFile(r, buf.Bytes(), 0o644))
+	assert.True(t, ecs.Unrelate[follows](wa, e1, e3))
+	buf.Reset()
+	assert.NoError(t, wa.MarshalTo(buf))
+	assert.NoError(t, os.WriteFile(b, buf.Bytes(), 0o644))
+	code, out = runCmd("diff", r, b)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "[[relations]]")
+	assert.Contains(t, out, `relation = "ecsctl.follows"`)
+	assert.NotContains(t, out, "[[components]]")
+
+	code, out = runCmd("validate", a, b)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "ok (2 entities)")
+
+	// toml -> json -> toml
+	j := filepath.Join(dir, "a.json")
+	c := filepath.Join(dir, "c.toml")
+	code, _ = runCmd("convert", a, j)
+	assert.Equal(t, 0, code)
+	code, _ = runCmd("convert", j, c)
+	assert.Equal(t, 0, code)
+	data, err := os.ReadFile(c)
+	assert.NoError(t, err)
+	w := ecs.NewEmptyWorld()
+	ecs.GetComponentStore[position](w)
+	assert.NoError(t, w.UnmarshalFromMode(bytes.NewReader(data), ecs.DeserializeStrict))
+	e, ok := w.EntityByName("player")
+	assert.True(t, ok)
+	ecs.Apply(w, e, func(p *position) {
+		assert.Equal(t, 1, p.X)
+		assert.NotEqual(t, ecs.Entity(0), p.Ref)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.toml")
+	assert.NoError(t, os.WriteFile(path, []byte(`[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+  [[entities.components]]
+    ci = 2
+    [entities.components.data]
+      X = 1
+[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+[[component_index]]
+  Name = "test.A"
+  Index = 1
+`), 0o644))
+	code, out := runCmd("validate", path)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, out, "component index 2 not found")
+	assert.Contains(t, out, "duplicate entity UUID")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(out), "1 invalid file(s)"))
+
+	code, _ = runCmd("nope")
+	assert.Equal(t, 2, code)
+}
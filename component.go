@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/gabstv/container"
@@ -26,41 +27,56 @@ type ComponentData[T ComponentType] struct {
 // IComponentStore is an interface for component stores.
 type IComponentStore interface {
 	Contains(e Entity) bool
+	Len() int
 	Remove(e Entity) bool
 	MergeJSONData(e Entity, jd []byte) error
 
+	containing(ents []Entity) []Entity
+	recordRemoved(ents []Entity)
+	removeMany(ents []Entity)
 	dataExtract(fn func(e Entity, d interface{}))
-	dataImport(e Entity, d toml.Primitive, md toml.MetaData) error
+	dataDecode(d toml.Primitive, md toml.MetaData, version int) (interface{}, error)
+	dataDecodeRaw(raw interface{}) (interface{}, error)
 	dataOf(e Entity) interface{}
+	dataReplace(e Entity, d interface{})
 	typeMatch(d interface{}) bool
+	snapshot(dst interface{}) interface{}
+	restore(s interface{})
 }
 
-// ComponentStore[T ComponentType] is a component data storage. The component data
-// is stored in a slice ordered by the Entity (ID; ascending).
+// ComponentStore[T ComponentType] is a component data storage. By default, the
+// component data is stored in a slice ordered by the Entity (ID; ascending).
+// See StorageKind for the other storage backends.
 type ComponentStore[T ComponentType] struct {
 	// data is an ordered entity slice of all entities that have this component.
-	data  []ComponentData[T]
-	world *World
-	zerov T
-	isptr *bool
+	// It is only used if backend is nil.
+	data    []ComponentData[T]
+	backend componentBackend[T]
+	world   *World
+	zerov   T
+	isptr   *bool
 
 	watchers container.Set[*ComponentWatcher[T]]
+	indexes  []componentIndexer[T]
 }
 
 // Apply passes a pointer of the component data to the function fn.
 // This is used to read or update data in the component.
 func (c *ComponentStore[T]) Apply(e Entity, fn func(*T)) bool {
-	index, exists := c.getIndex(e)
-	if !exists {
+	x := c.ptr(e)
+	if x == nil {
 		return false
 	}
-	x := &c.data[index]
-	fn(&x.Data)
+	fn(x)
+	c.reindex(e)
 	return true
 }
 
 // Contains returns true if the entity has data of this component store.
 func (c *ComponentStore[T]) Contains(e Entity) bool {
+	if c.backend != nil {
+		return c.backend.get(e) != nil
+	}
 	_, exists := c.getIndex(e)
 	return exists
 }
@@ -78,11 +94,21 @@ func (c *ComponentStore[T]) MergeJSONData(e Entity, jd []byte) error {
 // Remove removes the component data from this component store. It returns true
 // if the component data was found (and then removed).
 func (c *ComponentStore[T]) Remove(e Entity) bool {
-	index, exists := c.getIndex(e)
-	if !exists {
-		return false
+	if c.world.recording() != nil && c.Contains(e) {
+		c.record(e, nil)
+	}
+	if c.backend != nil {
+		if !c.backend.remove(e) {
+			return false
+		}
+	} else {
+		index, exists := c.getIndex(e)
+		if !exists {
+			return false
+		}
+		c.data = append(c.data[:index], c.data[index+1:]...)
 	}
-	c.data = append(c.data[:index], c.data[index+1:]...)
+	c.reindex(e)
 	c.watchers.Each(func(w *ComponentWatcher[T]) {
 		w.ComponentRemoved(e)
 	})
@@ -91,60 +117,274 @@ func (c *ComponentStore[T]) Remove(e Entity) bool {
 
 // Replace adds or replaces the component data for the given entity.
 func (c *ComponentStore[T]) Replace(e Entity, data T) {
-	// add to c.data
-	index, exists := c.getIndex(e)
-	if exists {
-		c.setDataAt(index, data)
-		return
+	c.record(e, data)
+	if c.backend != nil {
+		if x := c.backend.get(e); x != nil {
+			*x = data
+			c.reindex(e)
+			return
+		}
+		c.backend.insert(e, data)
+	} else {
+		// add to c.data
+		index, exists := c.getIndex(e)
+		if exists {
+			c.setDataAt(index, data)
+			c.reindex(e)
+			return
+		}
+		// insert data at index
+		c.data = Insert(c.data, index, ComponentData[T]{e, data})
 	}
-	// insert data at index
-	c.data = Insert(c.data, index, ComponentData[T]{e, data})
+	c.reindex(e)
 	c.watchers.Each(func(w *ComponentWatcher[T]) {
 		w.ComponentAdded(e)
 	})
 }
 
+// ReplaceBatch adds or replaces the component data of many entities at once.
+// ents and data must have the same length. The new data is merged into the
+// store in a single pass and the watchers are notified once per batch (see
+// ComponentWatcher.ComponentsAdded).
+func (c *ComponentStore[T]) ReplaceBatch(ents []Entity, data []T) {
+	if len(ents) != len(data) {
+		panic("ecs: ReplaceBatch: ents and data must have the same length")
+	}
+	if h := c.world.recording(); h != nil {
+		h.Begin("")
+		for i, e := range ents {
+			c.record(e, data[i])
+		}
+		h.End()
+	}
+	added := make([]Entity, 0, len(ents))
+	if c.backend != nil {
+		for i, e := range ents {
+			if x := c.backend.get(e); x != nil {
+				*x = data[i]
+				continue
+			}
+			c.backend.insert(e, data[i])
+			added = append(added, e)
+		}
+		SortEntities(added)
+	} else {
+		newdata := make([]ComponentData[T], len(ents))
+		for i, e := range ents {
+			newdata[i] = ComponentData[T]{e, data[i]}
+		}
+		if !sort.SliceIsSorted(newdata, func(i, j int) bool {
+			return newdata[i].Entity < newdata[j].Entity
+		}) {
+			sort.SliceStable(newdata, func(i, j int) bool {
+				return newdata[i].Entity < newdata[j].Entity
+			})
+		}
+		// replace the existing data (the last data of a repeated entity wins)
+		n := 0
+		index := 0
+		for i := range newdata {
+			if n > 0 && newdata[n-1].Entity == newdata[i].Entity {
+				newdata[n-1] = newdata[i]
+				continue
+			}
+			index = seekIndex(c.data, index, newdata[i].Entity)
+			if index < len(c.data) && c.data[index].Entity == newdata[i].Entity {
+				c.setDataAt(index, newdata[i].Data)
+				continue
+			}
+			newdata[n] = newdata[i]
+			n++
+		}
+		newdata = newdata[:n]
+		c.data = mergeComponentData(c.data, newdata)
+		for _, v := range newdata {
+			added = append(added, v.Entity)
+		}
+	}
+	if len(c.indexes) > 0 {
+		for _, e := range ents {
+			c.reindex(e)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	c.watchers.Each(func(w *ComponentWatcher[T]) {
+		w.componentsAdded(added)
+	})
+}
+
+// Len returns the number of entities that have this component.
+func (c *ComponentStore[T]) Len() int {
+	if c.backend != nil {
+		return c.backend.len()
+	}
+	return len(c.data)
+}
+
 // ComponentStore[T] privates
 
+// all returns the component data sorted by entity. If the store has a
+// backend, the data is a copy.
 func (c *ComponentStore[T]) all() []ComponentData[T] {
+	if c.backend != nil {
+		return c.backend.sorted()
+	}
 	return c.data
 }
 
+// ptr returns a pointer to the component data of the entity e (or nil).
+func (c *ComponentStore[T]) ptr(e Entity) *T {
+	if c.backend != nil {
+		return c.backend.get(e)
+	}
+	index, exists := c.getIndex(e)
+	if !exists {
+		return nil
+	}
+	return &c.data[index].Data
+}
+
+// containing returns the entities (sorted) that have this component.
+func (c *ComponentStore[T]) containing(ents []Entity) []Entity {
+	result := make([]Entity, 0, len(ents))
+	if c.backend != nil {
+		for _, e := range ents {
+			if c.backend.get(e) != nil {
+				result = append(result, e)
+			}
+		}
+		return result
+	}
+	index := 0
+	for _, e := range ents {
+		index = seekIndex(c.data, index, e)
+		if index < len(c.data) && c.data[index].Entity == e {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// recordRemoved records (see History) the removal of the component data of
+// the entities.
+func (c *ComponentStore[T]) recordRemoved(ents []Entity) {
+	if c.world.recording() == nil {
+		return
+	}
+	for _, e := range ents {
+		c.record(e, nil)
+	}
+}
+
+// removeMany removes the component data of the entities (sorted, and all with
+// the component) with a single compaction of the store.
+func (c *ComponentStore[T]) removeMany(ents []Entity) {
+	if len(ents) == 0 {
+		return
+	}
+	if c.backend != nil {
+		c.backend.removeMany(ents)
+	} else {
+		n := 0
+		i := 0
+		for _, cd := range c.data {
+			for i < len(ents) && ents[i] < cd.Entity {
+				i++
+			}
+			if i < len(ents) && ents[i] == cd.Entity {
+				continue
+			}
+			c.data[n] = cd
+			n++
+		}
+		var zv ComponentData[T]
+		for j := n; j < len(c.data); j++ {
+			c.data[j] = zv
+		}
+		c.data = c.data[:n]
+	}
+	for _, e := range ents {
+		c.reindex(e)
+	}
+	c.watchers.Each(func(w *ComponentWatcher[T]) {
+		w.componentsRemoved(ents)
+	})
+}
+
 func (c *ComponentStore[T]) dataExtract(fn func(e Entity, d interface{})) {
 	for _, v := range c.all() {
 		fn(v.Entity, v.Data)
 	}
 }
 
-func (c *ComponentStore[T]) dataImport(e Entity, d toml.Primitive, md toml.MetaData) error {
+// dataDecode decodes (and migrates, if the version is older) the component
+// data without adding it to the store. See dataReplace.
+func (c *ComponentStore[T]) dataDecode(d toml.Primitive, md toml.MetaData, version int) (interface{}, error) {
 	x := c.newType()
-	if c.isPointerType() {
+	if version != ComponentVersion(c.zerov.Pkg()) {
+		// old schema; the raw data needs to be migrated before decoding
+		var raw interface{}
+		if err := md.PrimitiveDecode(d, &raw); err != nil {
+			return nil, fmt.Errorf("failed to decode component %T: %v", x, err)
+		}
+		raw, err := migrateComponentData(c.zerov.Pkg(), raw, version)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeRaw(raw, &x); err != nil {
+			return nil, fmt.Errorf("failed to decode component %T: %v", x, err)
+		}
+	} else if c.isPointerType() {
 		if err := md.PrimitiveDecode(d, x); err != nil {
-			return fmt.Errorf("failed to decode component %T: %v", x, err)
+			return nil, fmt.Errorf("failed to decode component %T: %v", x, err)
 		}
 	} else {
 		if err := md.PrimitiveDecode(d, &x); err != nil {
-			return fmt.Errorf("failed to decode component %T: %v", x, err)
+			return nil, fmt.Errorf("failed to decode component %T: %v", x, err)
 		}
 	}
-	c.Replace(e, x)
-	return nil
+	return x, nil
+}
+
+// dataDecodeRaw decodes raw component data (see encodeRaw) without adding it
+// to the store.
+func (c *ComponentStore[T]) dataDecodeRaw(raw interface{}) (interface{}, error) {
+	x := c.newType()
+	if err := decodeRaw(raw, &x); err != nil {
+		return nil, fmt.Errorf("failed to decode component %T: %v", x, err)
+	}
+	return x, nil
 }
 
 func (c *ComponentStore[T]) dataOf(e Entity) interface{} {
-	i, exists := c.getIndex(e)
-	if !exists {
+	x := c.ptr(e)
+	if x == nil {
 		return nil
 	}
-	return c.data[i].Data
+	return *x
+}
+
+// record records a change of the component data of e in the history of the
+// world (if any). after is nil if the component is being removed.
+func (c *ComponentStore[T]) record(e Entity, after interface{}) {
+	if h := c.world.recording(); h != nil {
+		h.recordComponent(e, c.zerov.Pkg(), c.dataOf(e), after)
+	}
+}
+
+// dataReplace is the untyped version of Replace. It panics if d is not a T.
+func (c *ComponentStore[T]) dataReplace(e Entity, d interface{}) {
+	c.Replace(e, d.(T))
 }
 
 func (c *ComponentStore[T]) getCopy(e Entity) (T, bool) {
-	index, exists := c.getIndex(e)
-	if !exists {
+	x := c.ptr(e)
+	if x == nil {
 		return c.zerov, false
 	}
-	return c.data[index].Data, true
+	return *x, true
 }
 
 // getIndex does a binary search on c.data for the index of the entity
@@ -177,6 +417,22 @@ func (c *ComponentStore[T]) newType() T {
 	return y
 }
 
+// reindex updates the indexes of the store (see NewIndex) with the current
+// data of e.
+func (c *ComponentStore[T]) reindex(e Entity) {
+	if len(c.indexes) == 0 {
+		return
+	}
+	x := c.ptr(e)
+	for _, idx := range c.indexes {
+		if x == nil {
+			idx.indexRemove(e)
+		} else {
+			idx.indexSet(e, x)
+		}
+	}
+}
+
 func (c *ComponentStore[T]) setDataAt(index int, data T) {
 	c.data[index].Data = data
 }
@@ -190,8 +446,9 @@ func (c *ComponentStore[T]) typeMatch(d interface{}) bool {
 }
 
 type ComponentIndexEntry struct {
-	Name  string
-	Index int
+	Name    string
+	Index   int
+	Version int `toml:",omitzero"` // component schema version
 }
 
 type ComponentIndex []ComponentIndexEntry
@@ -204,6 +461,15 @@ func (e ComponentIndex) ToMap() map[string]int {
 	return m
 }
 
+var (
+	globalComponents = struct {
+		lock      sync.RWMutex
+		factories map[string]func(w *World) IComponentStore
+	}{
+		factories: make(map[string]func(w *World) IComponentStore),
+	}
+)
+
 // static fns
 
 // Apply updates the component data for the given entity.
@@ -232,10 +498,23 @@ func GetComponentStore[T ComponentType](w *World) *ComponentStore[T] {
 		data:  make([]ComponentData[T], 0),
 		world: w,
 	}
+	c.backend = newComponentBackend[T](w)
 	w.components[zv.Pkg()] = c
 	return c
 }
 
+// RegisterComponent registers the component type T globally (usually at init
+// time). Any world is then able to create the component store of T when it
+// finds the component name (Pkg()) in a save file or in GetGenericComponent.
+func RegisterComponent[T ComponentType]() {
+	var zv T
+	globalComponents.lock.Lock()
+	defer globalComponents.lock.Unlock()
+	globalComponents.factories[zv.Pkg()] = func(w *World) IComponentStore {
+		return GetComponentStore[T](w)
+	}
+}
+
 // RemoveComponent removes the component data for the given entity.
 // It returns false if the component was not found.
 func RemoveComponent[T ComponentType](w *World, e Entity) bool {
@@ -243,6 +522,14 @@ func RemoveComponent[T ComponentType](w *World, e Entity) bool {
 	return c.Remove(e)
 }
 
+// SetBatch replaces or inserts the component data of many entities at once
+// (see ComponentStore.ReplaceBatch). It is much faster than calling Set for
+// each entity when spawning many entities (see World.NewEntities).
+func SetBatch[T ComponentType](w *World, ents []Entity, data []T) {
+	c := GetComponentStore[T](w)
+	c.ReplaceBatch(ents, data)
+}
+
 // Set replaces or inserts the component data for the given entity.
 // If you junst need to update a value, use Apply() instead.
 func Set[T ComponentType](w *World, e Entity, data T) {
@@ -254,8 +541,9 @@ func componentIndexFromMap(m map[string]int) ComponentIndex {
 	x := make([]ComponentIndexEntry, 0, len(m))
 	for k, v := range m {
 		x = append(x, ComponentIndexEntry{
-			Name:  k,
-			Index: v,
+			Name:    k,
+			Index:   v,
+			Version: ComponentVersion(k),
 		})
 	}
 	sort.SliceStable(x, func(i, j int) bool {
@@ -278,6 +566,46 @@ func getEntityIndex(slc []Entity, e Entity) (int, bool) {
 	return x, false
 }
 
+// mergeComponentData merges two slices sorted by entity (without common
+// entities). It appends b when all of its entities come after a.
+func mergeComponentData[T ComponentType](a, b []ComponentData[T]) []ComponentData[T] {
+	if len(b) == 0 {
+		return a
+	}
+	if len(a) == 0 || a[len(a)-1].Entity < b[0].Entity {
+		return append(a, b...)
+	}
+	result := make([]ComponentData[T], 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i].Entity < b[j].Entity {
+			result = append(result, a[i])
+			i++
+		} else {
+			result = append(result, b[j])
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+// seekIndex returns the index of the first data with an entity >= e. All the
+// data before index must have entities < e. It is used to walk a sorted slice
+// with sorted entities.
+func seekIndex[T ComponentType](slc []ComponentData[T], index int, e Entity) int {
+	// the next entities are usually close
+	for n := 0; n < 8; n++ {
+		if index >= len(slc) || slc[index].Entity >= e {
+			return index
+		}
+		index++
+	}
+	return index + sort.Search(len(slc)-index, func(j int) bool {
+		return slc[index+j].Entity >= e
+	})
+}
+
 // getIndex does a binary search on c.data for the index of the entity
 func getIndex[T ComponentType](slc []ComponentData[T], e Entity) (int, bool) {
 	x := sort.Search(len(slc), func(i int) bool {
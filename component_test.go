@@ -1,6 +1,11 @@
 package ecs
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
 
 type Position struct {
 	X, Y int
@@ -88,3 +93,141 @@ func TestComponents(t *testing.T) {
 		t.Errorf("Get[Rotation] of e0 should fail")
 	}
 }
+
+type registeredComponent struct {
+	Name string
+}
+
+func (registeredComponent) Pkg() string {
+	return "test.registeredComponent"
+}
+
+func init() {
+	RegisterComponent[registeredComponent]()
+}
+
+func TestRegisterComponent(t *testing.T) {
+	w := NewWorld()
+	if w.GetGenericComponent("test.notRegistered") != nil {
+		t.Errorf("GetGenericComponent of an unregistered component should be nil")
+	}
+	if w.GetGenericComponent("test.registeredComponent") == nil {
+		t.Fatalf("GetGenericComponent of a registered component failed")
+	}
+	if w.GetGenericComponent("test.registeredComponent") != GetComponentStore[registeredComponent](w) {
+		t.Errorf("GetGenericComponent should return the same store")
+	}
+}
+
+func TestRegisterComponentUnmarshal(t *testing.T) {
+	w := NewWorld()
+	err := w.UnmarshalFromMode(strings.NewReader(`[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+
+  [[entities.components]]
+    ci = 1
+    [entities.components.data]
+      Name = "boss"
+
+[[component_index]]
+  Name = "test.registeredComponent"
+  Index = 1
+`), DeserializeStrict)
+	if err != nil {
+		t.Fatalf("UnmarshalFromMode failed: %v", err)
+	}
+	e, _ := w.EntityByUUID(uuid.MustParse("1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"))
+	var name string
+	Apply(w, e, func(v *registeredComponent) {
+		name = v.Name
+	})
+	if name != "boss" {
+		t.Errorf("registeredComponent.Name should be boss; got %q", name)
+	}
+}
+
+func TestSetBatch(t *testing.T) {
+	w := NewEmptyWorld()
+	e0 := w.NewEntity()
+	Set(w, e0, Position{X: -1})
+	view := NewView2[Position, Rotation](w, nil, nil)
+	added := make([]Entity, 0)
+	view.EntityAdded = func(e Entity) {
+		added = append(added, e)
+	}
+	nbatches := 0
+	watcher := newComponentWatcher(GetComponentStore[Position](w))
+	watcher.ComponentsAdded = func(ents []Entity) {
+		nbatches++
+	}
+	defer watcher.Destroy()
+
+	ents := w.NewEntities(100)
+	if len(ents) != 100 || ents[0] != e0+1 || ents[99] != e0+100 {
+		t.Fatal("unexpected entities", ents[0], ents[99])
+	}
+	pos := make([]Position, len(ents))
+	rot := make([]Rotation, len(ents))
+	for i := range ents {
+		pos[i] = Position{X: i}
+		rot[i] = Rotation{Value: i}
+	}
+	// unsorted, with an existing entity
+	batch := append([]Entity{e0}, ents[50:]...)
+	batch = append(batch, ents[:50]...)
+	bpos := append([]Position{{X: 1000}}, pos[50:]...)
+	bpos = append(bpos, pos[:50]...)
+	SetBatch(w, batch, bpos)
+	SetBatch(w, ents[:10], rot[:10])
+	Set(w, e0, Rotation{})
+
+	if nbatches != 1 {
+		t.Fatal("expected 1 batch notification, got", nbatches)
+	}
+	all := GetComponentStore[Position](w).all()
+	if len(all) != 101 {
+		t.Fatal("expected 101 positions, got", len(all))
+	}
+	for i, v := range all {
+		if v.Entity != e0+Entity(i) {
+			t.Fatal("positions are not sorted")
+		}
+	}
+	Apply(w, e0, func(p *Position) {
+		if p.X != 1000 {
+			t.Fatal("e0 was not replaced")
+		}
+	})
+	if view.Len() != 11 || len(added) != 11 {
+		t.Fatal("expected 11 entities in the view, got", view.Len(), len(added))
+	}
+	view.Each(func(e Entity, p *Position, r *Rotation) {
+		if e != e0 && p.X != r.Value {
+			t.Fatal("data mismatch", e, p.X, r.Value)
+		}
+	})
+}
+
+func BenchmarkSpawn(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		w := NewEmptyWorld()
+		NewView2[Position, Rotation](w, nil, nil)
+		for j := 0; j < 10000; j++ {
+			e := w.NewEntity()
+			Set(w, e, Position{X: j})
+			Set(w, e, Rotation{Value: j})
+		}
+	}
+}
+
+func BenchmarkSpawnBatch(b *testing.B) {
+	pos := make([]Position, 10000)
+	rot := make([]Rotation, 10000)
+	for i := 0; i < b.N; i++ {
+		w := NewEmptyWorld()
+		NewView2[Position, Rotation](w, nil, nil)
+		ents := w.NewEntities(10000)
+		SetBatch(w, ents, pos)
+		SetBatch(w, ents, rot)
+	}
+}
@@ -3,6 +3,14 @@ package ecs
 type ComponentWatcher[T ComponentType] struct {
 	ComponentAdded   func(e Entity)
 	ComponentRemoved func(e Entity)
+	// ComponentsAdded is called once when the component is added to many
+	// entities at once (see SetBatch). The entities are sorted. If nil,
+	// ComponentAdded is called for each entity.
+	ComponentsAdded func(ents []Entity)
+	// ComponentsRemoved is called once when the component is removed from
+	// many entities at once (see World.RemoveMany). The entities are sorted.
+	// If nil, ComponentRemoved is called for each entity.
+	ComponentsRemoved func(ents []Entity)
 
 	comp *ComponentStore[T]
 }
@@ -27,4 +35,26 @@ func (wa *ComponentWatcher[T]) Destroy() {
 	wa.comp = nil
 	wa.ComponentAdded = nil
 	wa.ComponentRemoved = nil
+	wa.ComponentsAdded = nil
+	wa.ComponentsRemoved = nil
+}
+
+func (wa *ComponentWatcher[T]) componentsAdded(ents []Entity) {
+	if wa.ComponentsAdded != nil {
+		wa.ComponentsAdded(ents)
+		return
+	}
+	for _, e := range ents {
+		wa.ComponentAdded(e)
+	}
+}
+
+func (wa *ComponentWatcher[T]) componentsRemoved(ents []Entity) {
+	if wa.ComponentsRemoved != nil {
+		wa.ComponentsRemoved(ents)
+		return
+	}
+	for _, e := range ents {
+		wa.ComponentRemoved(e)
+	}
 }
@@ -0,0 +1,394 @@
+package ecs
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+)
+
+// DeltaOp is the kind of change of a ComponentDelta.
+type DeltaOp string
+
+const (
+	DeltaAdded   DeltaOp = "added"
+	DeltaRemoved DeltaOp = "removed"
+	DeltaChanged DeltaOp = "changed"
+)
+
+// ComponentDelta is a change of a component of an entity.
+type ComponentDelta struct {
+	Entity    uuid.UUID `toml:"entity"`
+	Component string    `toml:"component"`
+	Op        DeltaOp   `toml:"op"`
+	// Data is the full component data (added) or only the changed fields
+	// (changed). A component that is not a table, or that had fields removed,
+	// is sent as added (replaced) with the full data.
+	Data interface{} `toml:"data,omitempty"`
+}
+
+// RelationDelta is a change of a relation between two entities. Changed
+// relations are sent as added (replaced) with the full data.
+type RelationDelta struct {
+	Relation string      `toml:"relation"`
+	Source   uuid.UUID   `toml:"source"`
+	Target   uuid.UUID   `toml:"target"`
+	Op       DeltaOp     `toml:"op"`
+	Data     interface{} `toml:"data,omitempty"`
+}
+
+// WorldDelta is the difference between two world states. It is computed by
+// Diff and applied by World.ApplyDelta. Entities are identified by their UUID.
+type WorldDelta struct {
+	Spawned    []uuid.UUID      `toml:"spawned"`
+	Despawned  []uuid.UUID      `toml:"despawned"`
+	Components []ComponentDelta `toml:"components"`
+	Relations  []RelationDelta  `toml:"relations,omitempty"`
+}
+
+// IsEmpty returns true if the delta has no changes.
+func (d *WorldDelta) IsEmpty() bool {
+	return len(d.Spawned) == 0 && len(d.Despawned) == 0 && len(d.Components) == 0 &&
+		len(d.Relations) == 0
+}
+
+// MarshalTo marshals the delta to a writer.
+func (d *WorldDelta) MarshalTo(dw io.Writer) error {
+	return toml.NewEncoder(dw).Encode(d)
+}
+
+// UnmarshalFrom reads a delta written by MarshalTo.
+func (d *WorldDelta) UnmarshalFrom(dr io.Reader) error {
+	if _, err := toml.NewDecoder(dr).Decode(d); err != nil {
+		return fmt.Errorf("failed to decode toml world delta: %w", err)
+	}
+	return nil
+}
+
+// SerializedState returns the world data as a SerializedWorld where all the
+// component and relation data is in its raw form: tables are
+// map[string]interface{} and entity references are UUID strings. Use it with
+// Diff.
+func (w *World) SerializedState() (SerializedWorld, error) {
+	encoderMutex.Lock()
+	defer encoderMutex.Unlock()
+	setEncoderWorld(w)
+	defer setEncoderWorld(nil)
+	sw := w.serializedWorld()
+	for _, ent := range sw.Entities {
+		for i, c := range ent.Components {
+			cd := c.(SerializedComponentData)
+			raw, err := encodeRaw(cd.Data)
+			if err != nil {
+				return sw, fmt.Errorf("failed to encode component %T of entity %s: %w", cd.Data, ent.UUID, err)
+			}
+			cd.Data = raw
+			ent.Components[i] = cd
+		}
+	}
+	for i, r := range sw.Relations {
+		raw, err := encodeRaw(r.Data)
+		if err != nil {
+			return sw, fmt.Errorf("failed to encode relation %s of entity %s: %w", r.Relation, r.Source, err)
+		}
+		sw.Relations[i].Data = raw
+	}
+	return sw, nil
+}
+
+// Diff computes the changes needed to transform the state "from" into "to".
+// The states should be obtained with World.SerializedState.
+func Diff(from, to SerializedWorld) *WorldDelta {
+	d := &WorldDelta{
+		Spawned:    make([]uuid.UUID, 0),
+		Despawned:  make([]uuid.UUID, 0),
+		Components: make([]ComponentDelta, 0),
+	}
+	fromEnts := serializedComponentsByUUID(from)
+	toEnts := serializedComponentsByUUID(to)
+	for _, ent := range to.Entities {
+		fromComps, existed := fromEnts[ent.UUID]
+		if !existed {
+			d.Spawned = append(d.Spawned, ent.UUID)
+		}
+		toComps := toEnts[ent.UUID]
+		for _, name := range sortedKeys(toComps) {
+			tov := toComps[name]
+			fromv, ok := fromComps[name]
+			if !ok {
+				d.Components = append(d.Components, ComponentDelta{
+					Entity:    ent.UUID,
+					Component: name,
+					Op:        DeltaAdded,
+					Data:      tov,
+				})
+				continue
+			}
+			if changed, partial, ok := diffData(fromv, tov); ok {
+				op := DeltaAdded
+				if partial {
+					op = DeltaChanged
+				}
+				d.Components = append(d.Components, ComponentDelta{
+					Entity:    ent.UUID,
+					Component: name,
+					Op:        op,
+					Data:      changed,
+				})
+			}
+		}
+		if !existed {
+			continue
+		}
+		for _, name := range sortedKeys(fromComps) {
+			if _, ok := toComps[name]; !ok {
+				d.Components = append(d.Components, ComponentDelta{
+					Entity:    ent.UUID,
+					Component: name,
+					Op:        DeltaRemoved,
+				})
+			}
+		}
+	}
+	for _, ent := range from.Entities {
+		if _, ok := toEnts[ent.UUID]; !ok {
+			d.Despawned = append(d.Despawned, ent.UUID)
+		}
+	}
+	d.Relations = diffRelations(from.Relations, to.Relations)
+	return d
+}
+
+type relationKey struct {
+	relation       string
+	source, target uuid.UUID
+}
+
+// diffRelations returns the relation changes from a to b
+func diffRelations(a, b []SerializedRelation) []RelationDelta {
+	result := make([]RelationDelta, 0)
+	prev := make(map[relationKey]interface{}, len(a))
+	for _, r := range a {
+		prev[relationKey{r.Relation, r.Source, r.Target}] = r.Data
+	}
+	next := make(map[relationKey]struct{}, len(b))
+	for _, r := range b {
+		k := relationKey{r.Relation, r.Source, r.Target}
+		next[k] = struct{}{}
+		if data, ok := prev[k]; ok && reflect.DeepEqual(data, r.Data) {
+			continue
+		}
+		result = append(result, RelationDelta{
+			Relation: r.Relation,
+			Source:   r.Source,
+			Target:   r.Target,
+			Op:       DeltaAdded,
+			Data:     r.Data,
+		})
+	}
+	for _, r := range a {
+		if _, ok := next[relationKey{r.Relation, r.Source, r.Target}]; !ok {
+			result = append(result, RelationDelta{
+				Relation: r.Relation,
+				Source:   r.Source,
+				Target:   r.Target,
+				Op:       DeltaRemoved,
+			})
+		}
+	}
+	return result
+}
+
+// ApplyDelta patches the world with the changes of a delta (see Diff).
+// Entities are matched by UUID; spawned entities are created if needed.
+// The components of the delta need to be registered in the world (or
+// globally with RegisterComponent). All the data is decoded before the world
+// is changed, so the world is left untouched if an error is returned.
+func (w *World) ApplyDelta(d *WorldDelta) error {
+	// partial changes encode the current data
+	defer w.lockCodec()()
+
+	// entities referenced by the data are created while decoding, so they
+	// are discarded if the delta is invalid
+	last := w.beginDecoding()
+	components, relations, err := w.decodeDelta(d)
+	w.endDecoding(last, err != nil)
+	if err != nil {
+		return err
+	}
+	for _, id := range d.Spawned {
+		_ = w.getEntityByUUID(id)
+	}
+	for _, c := range components {
+		if c.data == nil {
+			if e, ok := w.EntityByUUID(c.entity); ok {
+				c.store.Remove(e)
+			}
+			continue
+		}
+		c.store.dataReplace(w.getEntityByUUID(c.entity), c.data)
+	}
+	for _, r := range relations {
+		if r.data == nil {
+			source, ok1 := w.EntityByUUID(r.source)
+			target, ok2 := w.EntityByUUID(r.target)
+			if ok1 && ok2 {
+				r.store.dataUnrelate(source, target)
+			}
+			continue
+		}
+		r.store.dataRelate(w.getEntityByUUID(r.source), w.getEntityByUUID(r.target), r.data)
+	}
+	for _, id := range d.Despawned {
+		if e, ok := w.EntityByUUID(id); ok {
+			w.Remove(e)
+		}
+	}
+	return nil
+}
+
+// deltaComponent is a decoded ComponentDelta (data is nil if the component
+// is removed).
+type deltaComponent struct {
+	store  IComponentStore
+	entity uuid.UUID
+	data   interface{}
+}
+
+// deltaRelation is a decoded RelationDelta (data is nil if the relation is
+// removed).
+type deltaRelation struct {
+	store          IRelationStore
+	source, target uuid.UUID
+	data           interface{}
+}
+
+// decodeDelta decodes the component and relation data of a delta. It must be
+// called with the world as the encoder and decoder world.
+func (w *World) decodeDelta(d *WorldDelta) ([]deltaComponent, []deltaRelation, error) {
+	components := make([]deltaComponent, 0, len(d.Components))
+	for _, cd := range d.Components {
+		store := w.GetGenericComponent(cd.Component)
+		if store == nil {
+			return nil, nil, &DeserializeError{
+				EntityUUID: cd.Entity,
+				Component:  cd.Component,
+				Err:        ErrUnknownComponent,
+			}
+		}
+		if cd.Op == DeltaRemoved {
+			components = append(components, deltaComponent{
+				store:  store,
+				entity: cd.Entity,
+			})
+			continue
+		}
+		raw := cd.Data
+		if cd.Op == DeltaChanged {
+			e, ok := w.EntityByUUID(cd.Entity)
+			if fields, isMap := raw.(map[string]interface{}); isMap && ok && store.Contains(e) {
+				cur, err := encodeRaw(store.dataOf(e))
+				if err != nil {
+					return nil, nil, &DeserializeError{EntityUUID: cd.Entity, Component: cd.Component, Err: err}
+				}
+				if curm, ok := cur.(map[string]interface{}); ok {
+					for k, v := range fields {
+						curm[k] = v
+					}
+					raw = curm
+				}
+			}
+		}
+		v, err := store.dataDecodeRaw(raw)
+		if err != nil {
+			return nil, nil, &DeserializeError{EntityUUID: cd.Entity, Component: cd.Component, Err: err}
+		}
+		components = append(components, deltaComponent{
+			store:  store,
+			entity: cd.Entity,
+			data:   v,
+		})
+	}
+	relations := make([]deltaRelation, 0, len(d.Relations))
+	for _, rd := range d.Relations {
+		store := w.GetGenericRelation(rd.Relation)
+		if store == nil {
+			return nil, nil, &DeserializeError{
+				EntityUUID: rd.Source,
+				Component:  rd.Relation,
+				Err:        ErrUnknownRelation,
+			}
+		}
+		dr := deltaRelation{
+			store:  store,
+			source: rd.Source,
+			target: rd.Target,
+		}
+		if rd.Op != DeltaRemoved {
+			v, err := store.dataDecodeRaw(rd.Data)
+			if err != nil {
+				return nil, nil, &DeserializeError{EntityUUID: rd.Source, Component: rd.Relation, Err: err}
+			}
+			dr.data = v
+		}
+		relations = append(relations, dr)
+	}
+	return components, relations, nil
+}
+
+// serializedComponentsByUUID maps the component data of a serialized world
+// by entity UUID and component name.
+func serializedComponentsByUUID(sw SerializedWorld) map[uuid.UUID]map[string]interface{} {
+	names := make(map[int]string)
+	for _, v := range sw.ComponentIndex {
+		names[v.Index] = v.Name
+	}
+	m := make(map[uuid.UUID]map[string]interface{}, len(sw.Entities))
+	for _, ent := range sw.Entities {
+		comps := make(map[string]interface{}, len(ent.Components))
+		for _, c := range ent.Components {
+			cd := c.(SerializedComponentData)
+			comps[names[cd.CI]] = cd.Data
+		}
+		m[ent.UUID] = comps
+	}
+	return m
+}
+
+// diffData returns the data that changed between a and b. If both are tables
+// (and b has all the fields of a), only the changed fields are returned and
+// partial is true.
+func diffData(a, b interface{}) (changed interface{}, partial, ok bool) {
+	if reflect.DeepEqual(a, b) {
+		return nil, false, false
+	}
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		return b, false, true
+	}
+	for k := range am {
+		if _, ok := bm[k]; !ok {
+			return b, false, true
+		}
+	}
+	fields := make(map[string]interface{})
+	for k, v := range bm {
+		if !reflect.DeepEqual(am[k], v) {
+			fields[k] = v
+		}
+	}
+	return fields, true, true
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,185 @@
+package ecs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type diffTarget struct {
+	Target Entity
+	Label  string
+}
+
+func (diffTarget) Pkg() string {
+	return "test.diffTarget"
+}
+
+func TestDiffAndApplyDelta(t *testing.T) {
+	w := NewWorld()
+	e1 := w.NewEntity()
+	e2 := w.NewEntity()
+	e3 := w.NewEntity()
+	Set(w, e1, BenchPos3{X: 1, Y: 2, Z: 3})
+	Set(w, e1, BenchSpeed3{Xs: 1})
+	Set(w, e2, BenchPos3{X: 4})
+	Set(w, e3, BenchAccel{Xa: 1})
+
+	// the client world starts as a copy of w
+	buf := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(buf))
+	client := NewWorld()
+	_ = GetComponentStore[BenchPos3](client)
+	_ = GetComponentStore[BenchSpeed3](client)
+	_ = GetComponentStore[BenchAccel](client)
+	_ = GetComponentStore[diffTarget](client)
+	assert.NoError(t, client.UnmarshalFrom(buf))
+
+	s1, err := w.SerializedState()
+	assert.NoError(t, err)
+
+	Apply(w, e1, func(p *BenchPos3) {
+		p.Y = 20
+	})
+	RemoveComponent[BenchSpeed3](w, e1)
+	Set(w, e2, BenchAccel{Ya: 2})
+	w.Remove(e3)
+	e4 := w.NewEntity()
+	Set(w, e4, diffTarget{Target: e2, Label: "follow"})
+
+	s2, err := w.SerializedState()
+	assert.NoError(t, err)
+	delta := Diff(s1, s2)
+	assert.Equal(t, 1, len(delta.Spawned))
+	assert.Equal(t, w.EntityUUID(e4), delta.Spawned[0])
+	assert.Equal(t, 1, len(delta.Despawned))
+	assert.Equal(t, 4, len(delta.Components))
+	assert.Equal(t, DeltaChanged, delta.Components[0].Op)
+	assert.Equal(t, map[string]interface{}{"Y": 20.0}, delta.Components[0].Data)
+
+	// the delta is serializable
+	buf.Reset()
+	assert.NoError(t, delta.MarshalTo(buf))
+	delta2 := &WorldDelta{}
+	assert.NoError(t, delta2.UnmarshalFrom(buf))
+
+	assert.NoError(t, client.ApplyDelta(delta2))
+	cs, err := client.SerializedState()
+	assert.NoError(t, err)
+	assert.True(t, Diff(s2, cs).IsEmpty())
+	assert.True(t, Diff(cs, s2).IsEmpty())
+
+	ce4, ok := client.EntityByUUID(w.EntityUUID(e4))
+	assert.True(t, ok)
+	ce2, _ := client.EntityByUUID(w.EntityUUID(e2))
+	assert.True(t, Apply(client, ce4, func(v *diffTarget) {
+		assert.Equal(t, ce2, v.Target)
+	}))
+	_, ok = client.EntityByUUID(s1.Entities[2].UUID)
+	assert.False(t, ok)
+}
+
+func TestDiffEntitiesWithoutComponents(t *testing.T) {
+	w := NewWorld()
+	e1 := w.NewEntity()
+	Set(w, e1, BenchPos3{X: 1})
+	s1, err := w.SerializedState()
+	assert.NoError(t, err)
+
+	// losing the last component is not a despawn
+	RemoveComponent[BenchPos3](w, e1)
+	e2 := w.NewEntity()
+	s2, err := w.SerializedState()
+	assert.NoError(t, err)
+	delta := Diff(s1, s2)
+	assert.Equal(t, 0, len(delta.Despawned))
+	assert.Equal(t, []uuid.UUID{w.EntityUUID(e2)}, delta.Spawned)
+	assert.Equal(t, 1, len(delta.Components))
+	assert.Equal(t, DeltaRemoved, delta.Components[0].Op)
+
+	client := NewWorld()
+	_ = GetComponentStore[BenchPos3](client)
+	assert.NoError(t, client.ApplyDelta(Diff(SerializedWorld{}, s1)))
+	assert.NoError(t, client.ApplyDelta(delta))
+	assert.Equal(t, 2, len(client.entities))
+	ce1, ok := client.EntityByUUID(w.EntityUUID(e1))
+	assert.True(t, ok)
+	assert.False(t, Contains[BenchPos3](client, ce1))
+}
+
+func TestDiffRelations(t *testing.T) {
+	w := NewWorld()
+	ship := w.NewEntity()
+	player := w.NewEntity()
+	station := w.NewEntity()
+	assert.NoError(t, Relate(w, ship, player, ownedBy{Since: 1}))
+	assert.NoError(t, Relate(w, ship, station, ownedBy{Since: 2}))
+	s1, err := w.SerializedState()
+	assert.NoError(t, err)
+	client := NewWorld()
+	assert.NoError(t, client.ApplyDelta(Diff(SerializedWorld{}, s1)))
+
+	assert.NoError(t, Relate(w, ship, player, ownedBy{Since: 3}))
+	Unrelate[ownedBy](w, ship, station)
+	s2, err := w.SerializedState()
+	assert.NoError(t, err)
+	delta := Diff(s1, s2)
+	assert.Equal(t, 0, len(delta.Components))
+	assert.Equal(t, 2, len(delta.Relations))
+	assert.Equal(t, DeltaAdded, delta.Relations[0].Op)
+	assert.Equal(t, DeltaRemoved, delta.Relations[1].Op)
+
+	assert.NoError(t, client.ApplyDelta(delta))
+	cs, err := client.SerializedState()
+	assert.NoError(t, err)
+	assert.True(t, Diff(s2, cs).IsEmpty())
+	cship, _ := client.EntityByUUID(w.EntityUUID(ship))
+	cplayer, _ := client.EntityByUUID(w.EntityUUID(player))
+	d, ok := GetRelation[ownedBy](client, cship, cplayer)
+	assert.True(t, ok)
+	assert.Equal(t, 3, d.Since)
+	assert.Equal(t, 1, GetRelationStore[ownedBy](client).Len())
+}
+
+func TestApplyDeltaInvalid(t *testing.T) {
+	client := NewWorld()
+	_ = GetComponentStore[diffTarget](client)
+	spawned := uuid.New()
+	delta := &WorldDelta{
+		Spawned: []uuid.UUID{spawned},
+		Components: []ComponentDelta{
+			{
+				Entity:    spawned,
+				Component: "test.diffTarget",
+				Op:        DeltaAdded,
+				Data: map[string]interface{}{
+					"Target": uuid.New().String(),
+				},
+			},
+			{
+				Entity:    spawned,
+				Component: "test.NotRegistered",
+				Op:        DeltaAdded,
+				Data:      map[string]interface{}{},
+			},
+		},
+	}
+	assert.ErrorIs(t, client.ApplyDelta(delta), ErrUnknownComponent)
+	// nothing was applied, not even the entity referenced by the target
+	assert.Equal(t, 0, len(client.entities))
+	assert.Equal(t, 0, GetComponentStore[diffTarget](client).Len())
+
+	// removing a component of an unknown entity doesn't spawn it
+	assert.NoError(t, client.ApplyDelta(&WorldDelta{
+		Components: []ComponentDelta{
+			{
+				Entity:    uuid.New(),
+				Component: "test.diffTarget",
+				Op:        DeltaRemoved,
+			},
+		},
+	}))
+	assert.Equal(t, 0, len(client.entities))
+}
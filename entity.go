@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/BurntSushi/toml"
@@ -12,24 +13,46 @@ import (
 
 type Entity uint64
 
+// entityBinarySize is the size of a binary encoded entity (UUID)
+const entityBinarySize = 16
+
+var (
+	errNoEncoderWorld = errors.New("no encoder world set")
+	errNoDecoderWorld = errors.New("no decoder world set")
+)
+
+// MarshalBinary encodes the entity as the 16 bytes of its UUID. The zero
+// (null) entity is encoded as the nil UUID.
 func (e Entity) MarshalBinary() ([]byte, error) {
-	id := encoderWorld.EntityUUID(e)
-	if id.Version() < 1 {
+	if e == 0 {
+		return make([]byte, entityBinarySize), nil
+	}
+	if encoderWorld == nil {
+		return nil, errNoEncoderWorld
+	}
+	id := encoderEntityUUID(e)
+	if id == uuid.Nil {
 		return nil, fmt.Errorf("entity %d has no UUID", e)
 	}
 	return id[:], nil
 }
 
+// UnmarshalBinary decodes an entity encoded by MarshalBinary. The entity is
+// resolved by its UUID in the decoder world (a new entity is created if the
+// UUID is not found). The nil UUID is decoded as the zero entity.
 func (e *Entity) UnmarshalBinary(data []byte) error {
-	if len(data) != 16 {
+	if len(data) != entityBinarySize {
 		return fmt.Errorf("invalid UUID length: %d", len(data))
 	}
 	var d [16]byte
 	copy(d[:], data)
 	id := uuid.UUID(d)
-
-	if id.Version() < 1 {
-		return fmt.Errorf("invalid UUID")
+	if id == uuid.Nil {
+		*e = 0
+		return nil
+	}
+	if decoderWorld == nil {
+		return errNoDecoderWorld
 	}
 	*e = decoderWorld.getEntityByUUID(id)
 	return nil
@@ -39,7 +62,7 @@ func (e Entity) MarshalText() (text []byte, err error) {
 	if e == 0 {
 		return nil, nil
 	}
-	return []byte(encoderWorld.EntityUUID(e).String()), nil
+	return []byte(encoderEntityUUID(e).String()), nil
 }
 
 func (e *Entity) UnmarshalText(text []byte) error {
@@ -74,38 +97,52 @@ func (e *Entity) UnmarshalJSON(b []byte) error {
 }
 
 func (e Entity) MarshalJSON() ([]byte, error) {
-	s := encoderWorld.EntityUUID(e).String()
+	s := encoderEntityUUID(e).String()
 	return json.Marshal(s)
 }
 
 type Entities []Entity
 
+// MarshalBinary encodes the entities as an uvarint length followed by the
+// binary encoding of each entity (see Entity.MarshalBinary).
 func (e Entities) MarshalBinary() ([]byte, error) {
-	eslice := make([]byte, len(e)*16+8)
+	eslice := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(e)*entityBinarySize)
 	offset := binary.PutUvarint(eslice, uint64(len(e)))
-	if offset != 8 {
-		panic("unexpected offset")
-	}
+	eslice = eslice[:offset]
 	for _, e := range e {
-		id := encoderWorld.EntityUUID(e)
-		if id.Version() < 1 {
-			return nil, fmt.Errorf("entity %d has no UUID", e)
+		id, err := e.MarshalBinary()
+		if err != nil {
+			return nil, err
 		}
-		copy(eslice[offset:], id[:])
-		offset += 16
+		eslice = append(eslice, id...)
 	}
-	return eslice[:], nil
+	return eslice, nil
 }
 
+// UnmarshalBinary decodes the entities encoded by MarshalBinary.
 func (e *Entities) UnmarshalBinary(data []byte) error {
-	//TODO: implement (e *Entities) UnmarshalBinary(data []byte) error
-	return fmt.Errorf("not implemented")
+	n, offset := binary.Uvarint(data)
+	if offset <= 0 {
+		return fmt.Errorf("invalid entities length header")
+	}
+	data = data[offset:]
+	if n > uint64(len(data)/entityBinarySize) || uint64(len(data)) != n*entityBinarySize {
+		return fmt.Errorf("invalid entities data length: %d entities in %d bytes", n, len(data))
+	}
+	eslc := make([]Entity, n)
+	for i := range eslc {
+		if err := eslc[i].UnmarshalBinary(data[i*entityBinarySize : (i+1)*entityBinarySize]); err != nil {
+			return err
+		}
+	}
+	*e = Entities(eslc)
+	return nil
 }
 
 func (e Entities) MarshalText() (text []byte, err error) {
 	slcs := make([]string, 0, len(e))
 	for _, e := range e {
-		slcs = append(slcs, encoderWorld.EntityUUID(e).String())
+		slcs = append(slcs, encoderEntityUUID(e).String())
 	}
 	buf := new(bytes.Buffer)
 	if err := toml.NewEncoder(buf).Encode(slcs); err != nil {
@@ -151,7 +188,7 @@ func (e *Entities) UnmarshalJSON(b []byte) error {
 func (e Entities) MarshalJSON() ([]byte, error) {
 	slc := make([]string, 0, len(e))
 	for _, e := range e {
-		slc = append(slc, encoderWorld.EntityUUID(e).String())
+		slc = append(slc, encoderEntityUUID(e).String())
 	}
 	return json.Marshal(slc)
 }
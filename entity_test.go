@@ -0,0 +1,132 @@
+package ecs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withCodecWorlds(enc, dec *World, fn func()) {
+	encoderMutex.Lock()
+	defer encoderMutex.Unlock()
+	decoderMutex.Lock()
+	defer decoderMutex.Unlock()
+	setEncoderWorld(enc)
+	defer setEncoderWorld(nil)
+	setDecoderWorld(dec)
+	defer setDecoderWorld(nil)
+	fn()
+}
+
+func TestEntitiesBinary(t *testing.T) {
+	w1 := NewWorld()
+	w2 := NewWorld()
+	src := Entities{w1.NewEntity(), 0, w1.NewEntity(), w1.NewEntity()}
+	var dst Entities
+	withCodecWorlds(w1, w2, func() {
+		data, err := src.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, 1+4*16, len(data))
+		assert.NoError(t, dst.UnmarshalBinary(data))
+	})
+	assert.Equal(t, len(src), len(dst))
+	assert.Equal(t, Entity(0), dst[1])
+	for i := range src {
+		if src[i] == 0 {
+			continue
+		}
+		assert.Equal(t, w1.EntityUUID(src[i]), w2.EntityUUID(dst[i]))
+	}
+	withCodecWorlds(w1, w2, func() {
+		var e Entities
+		assert.Error(t, e.UnmarshalBinary(nil))
+		assert.Error(t, e.UnmarshalBinary([]byte{2, 0, 0}))
+		data, err := Entities{}.MarshalBinary()
+		assert.NoError(t, err)
+		assert.NoError(t, e.UnmarshalBinary(data))
+		assert.Equal(t, 0, len(e))
+	})
+}
+
+func FuzzEntitiesUnmarshalBinary(f *testing.F) {
+	w := NewWorld()
+	for i := 0; i < 4; i++ {
+		w.NewEntity()
+	}
+	withCodecWorlds(w, w, func() {
+		for _, seed := range []Entities{{}, {0}, {1, 2}, {4, 0, 3, 3}} {
+			data, err := seed.MarshalBinary()
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(data)
+		}
+	})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		withCodecWorlds(w, w, func() {
+			var e Entities
+			if err := e.UnmarshalBinary(data); err != nil {
+				return
+			}
+			data2, err := e.MarshalBinary()
+			if err != nil {
+				t.Fatalf("failed to marshal decoded entities: %v", err)
+			}
+			var e2 Entities
+			if err := e2.UnmarshalBinary(data2); err != nil {
+				t.Fatalf("failed to unmarshal encoded entities: %v", err)
+			}
+			if len(e) != len(e2) {
+				t.Fatalf("length mismatch: %d != %d", len(e), len(e2))
+			}
+			for i := range e {
+				if e[i] != e2[i] {
+					t.Fatalf("entity %d mismatch: %d != %d", i, e[i], e2[i])
+				}
+			}
+			// uuids are preserved
+			if !bytes.Equal(data[len(data)-len(e)*16:], data2[len(data2)-len(e2)*16:]) {
+				t.Fatalf("uuid mismatch")
+			}
+		})
+	})
+}
+
+func FuzzEntitiesBinaryRoundTrip(f *testing.F) {
+	f.Add(uint8(0), uint64(0))
+	f.Add(uint8(3), uint64(0b010))
+	f.Add(uint8(200), uint64(0xf0f0))
+	f.Fuzz(func(t *testing.T, n uint8, nulls uint64) {
+		w1 := NewEmptyWorld()
+		w2 := NewEmptyWorld()
+		src := make(Entities, int(n))
+		for i := range src {
+			if nulls&(1<<(i%64)) == 0 {
+				src[i] = w1.NewEntity()
+			}
+		}
+		var dst Entities
+		withCodecWorlds(w1, w2, func() {
+			data, err := src.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dst.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if len(src) != len(dst) {
+			t.Fatalf("length mismatch: %d != %d", len(src), len(dst))
+		}
+		for i := range src {
+			if src[i] == 0 && dst[i] != 0 {
+				t.Fatalf("null entity %d decoded as %d", i, dst[i])
+			}
+			if src[i] != 0 && w1.EntityUUID(src[i]) != w2.EntityUUID(dst[i]) {
+				t.Fatalf("entity %d uuid mismatch", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package ecs
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+)
+
+// RecordedEvent is an event recorded by an EventRecorder. The payload (Data) is
+// stored in its raw form, so entity references are UUIDs.
+type RecordedEvent struct {
+	Tick   uint64      `toml:"tick"`
+	Name   string      `toml:"name"`
+	Entity uuid.UUID   `toml:"entity"` // the target entity (nil UUID if not targeted)
+	Data   interface{} `toml:"data,omitempty"`
+}
+
+// EventLog is a list of recorded events. It can be saved (MarshalTo) and
+// replayed into another world (Replay).
+type EventLog struct {
+	Events []RecordedEvent `toml:"events"`
+}
+
+// MarshalTo marshals the event log to a writer.
+func (l *EventLog) MarshalTo(dw io.Writer) error {
+	return toml.NewEncoder(dw).Encode(l)
+}
+
+// UnmarshalFrom reads an event log written by MarshalTo.
+func (l *EventLog) UnmarshalFrom(dr io.Reader) error {
+	if _, err := toml.NewDecoder(dr).Decode(l); err != nil {
+		return fmt.Errorf("failed to decode toml event log: %w", err)
+	}
+	return nil
+}
+
+// Replay fires all the events of the log into the world w, in the order they
+// were recorded. Entity references are resolved by UUID. Payloads of event
+// types registered with RegisterEventType are decoded to their types; other
+// payloads are passed in their raw form.
+func (l *EventLog) Replay(w *World) error {
+	for i := range l.Events {
+		if err := l.Events[i].replay(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplayTick fires the events of the log that were recorded at the given tick
+// (see World.Tick). It is meant to be called before each World.Step to
+// reproduce the original timing.
+func (l *EventLog) ReplayTick(w *World, tick uint64) error {
+	for i := range l.Events {
+		if l.Events[i].Tick != tick {
+			continue
+		}
+		if err := l.Events[i].replay(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (re *RecordedEvent) replay(w *World) error {
+	var target Entity
+	data, err := func() (interface{}, error) {
+		decoderMutex.Lock()
+		defer decoderMutex.Unlock()
+		setDecoderWorld(w)
+		defer setDecoderWorld(nil)
+		if re.Entity != uuid.Nil {
+			target = w.getEntityByUUID(re.Entity)
+		}
+		globalEventTypes.lock.RLock()
+		decode := globalEventTypes.decoders[re.Name]
+		globalEventTypes.lock.RUnlock()
+		if decode == nil || re.Data == nil {
+			return re.Data, nil
+		}
+		return decode(re.Data)
+	}()
+	if err != nil {
+		return fmt.Errorf("failed to decode event %s (tick %d): %w", re.Name, re.Tick, err)
+	}
+	if target != 0 {
+		w.FireEntityEvent(target, re.Name, data)
+	} else {
+		w.FireEvent(re.Name, data)
+	}
+	return nil
+}
+
+// EventRecorder records the events fired through a world (FireEvent,
+// FireEntityEvent and their typed versions). Only the events that come from
+// outside of the world are recorded, since the others happen again when the
+// world is replayed (see EventLog.ReplayTick): events fired by listeners while
+// another event is dispatched, events fired by systems while the world is
+// stepped (Step and StepF) and events written to queues (WriteEvent) are not
+// recorded.
+type EventRecorder struct {
+	world *World
+	log   *EventLog
+	err   error
+}
+
+// Log returns the recorded events.
+func (r *EventRecorder) Log() *EventLog {
+	return r.log
+}
+
+// Err returns the first error found while encoding an event payload. Events
+// with payloads that can't be encoded are recorded without data.
+func (r *EventRecorder) Err() error {
+	return r.err
+}
+
+// Stop stops recording and returns the recorded events.
+func (r *EventRecorder) Stop() *EventLog {
+	m := r.world.eventManager
+	m.l.Lock()
+	defer m.l.Unlock()
+	if m.recorder == r {
+		m.recorder = nil
+	}
+	return r.log
+}
+
+func (r *EventRecorder) record(name string, e Entity, data interface{}) {
+	re := RecordedEvent{
+		Tick: r.world.tick,
+		Name: name,
+	}
+	if e != 0 {
+		re.Entity = r.world.EntityUUID(e)
+	}
+	raw, err := r.world.encodeRaw(data)
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("failed to encode event %s: %w", name, err)
+		}
+	} else {
+		re.Data = raw
+	}
+	r.log.Events = append(r.log.Events, re)
+}
+
+// RecordEvents starts recording the events of the world. Only one recorder
+// can be active at a time; the previous recorder (if any) is stopped.
+func (w *World) RecordEvents() *EventRecorder {
+	r := &EventRecorder{
+		world: w,
+		log: &EventLog{
+			Events: make([]RecordedEvent, 0),
+		},
+	}
+	w.eventManager.l.Lock()
+	defer w.eventManager.l.Unlock()
+	w.eventManager.recorder = r
+	return r
+}
+
+var (
+	globalEventTypes = struct {
+		lock     sync.RWMutex
+		decoders map[string]func(raw interface{}) (interface{}, error)
+	}{
+		decoders: make(map[string]func(raw interface{}) (interface{}, error)),
+	}
+)
+
+// RegisterEventType registers the event type T globally, so the payloads of
+// replayed events (see EventLog) are decoded to T.
+func RegisterEventType[T EventType]() {
+	var zv T
+	globalEventTypes.lock.Lock()
+	defer globalEventTypes.lock.Unlock()
+	globalEventTypes.decoders[zv.Pkg()] = func(raw interface{}) (interface{}, error) {
+		var v T
+		if err := decodeRaw(raw, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
@@ -0,0 +1,149 @@
+package ecs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type attackEvent struct {
+	Attacker Entity
+	Damage   float64
+}
+
+func (attackEvent) Pkg() string {
+	return "test.attackEvent"
+}
+
+func init() {
+	RegisterEventType[attackEvent]()
+}
+
+func TestEventRecordReplay(t *testing.T) {
+	w := NewWorld()
+	player := w.NewEntity()
+	enemy := w.NewEntity()
+	Set(w, player, Position{X: 1})
+	Set(w, enemy, Position{X: 2})
+	saved := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(saved))
+
+	rec := w.RecordEvents()
+	EmitTo(w, player, attackEvent{Attacker: enemy, Damage: 3.5})
+	w.Step()
+	Emit(w, attackEvent{Attacker: player, Damage: 1})
+	w.FireEvent("test.untyped", map[string]interface{}{"Value": "x"})
+	log := rec.Stop()
+	w.FireEvent("test.untyped", nil)
+	assert.NoError(t, rec.Err())
+	assert.Equal(t, 3, len(log.Events))
+	assert.Equal(t, uint64(0), log.Events[0].Tick)
+	assert.Equal(t, uint64(1), log.Events[1].Tick)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, log.MarshalTo(buf))
+	log2 := &EventLog{}
+	assert.NoError(t, log2.UnmarshalFrom(buf))
+
+	w2 := NewWorld()
+	_ = GetComponentStore[Position](w2)
+	assert.NoError(t, w2.UnmarshalFrom(saved))
+	player2, _ := w2.EntityByUUID(w.EntityUUID(player))
+	enemy2, _ := w2.EntityByUUID(w.EntityUUID(enemy))
+	attacks := make([]attackEvent, 0)
+	OnEntityEvent(w2, player2, func(e Entity, v attackEvent) {
+		attacks = append(attacks, v)
+	})
+	OnEvent(w2, func(v attackEvent) {
+		attacks = append(attacks, v)
+	})
+	untyped := 0
+	w2.OnEvent("test.untyped", func(e Event) {
+		assert.Equal(t, map[string]interface{}{"Value": "x"}, e.Data)
+		untyped++
+	})
+	assert.NoError(t, log2.ReplayTick(w2, 0))
+	assert.Equal(t, []attackEvent{
+		{Attacker: enemy2, Damage: 3.5},
+		{Attacker: enemy2, Damage: 3.5},
+	}, attacks)
+	assert.NoError(t, log2.ReplayTick(w2, 1))
+	assert.Equal(t, attackEvent{Attacker: player2, Damage: 1}, attacks[2])
+	assert.Equal(t, 1, untyped)
+}
+
+func TestEventRecordNested(t *testing.T) {
+	w := NewWorld()
+	hits := 0
+	w.OnEvent("test.hit", func(e Event) {
+		w.FireEvent("test.damaged", nil)
+	})
+	w.OnEvent("test.damaged", func(e Event) {
+		hits++
+	})
+	rec := w.RecordEvents()
+	w.FireEvent("test.hit", nil)
+	log := rec.Stop()
+	assert.Equal(t, 1, len(log.Events))
+	assert.Equal(t, "test.hit", log.Events[0].Name)
+	assert.Equal(t, 1, hits)
+
+	// the nested event is fired once by the replayed event
+	assert.NoError(t, log.Replay(w))
+	assert.Equal(t, 2, hits)
+}
+
+func TestEventRecordWhileLoading(t *testing.T) {
+	w := NewWorld()
+	e1 := w.NewEntity()
+	Set(w, e1, Position{X: 1})
+	saved := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(saved))
+	s1, err := w.SerializedState()
+	assert.NoError(t, err)
+	e2 := w.NewEntity()
+	Set(w, e2, Position{X: 2})
+	s2, err := w.SerializedState()
+	assert.NoError(t, err)
+
+	w2 := NewWorld()
+	view := NewView[Position](w2, func(e Entity) {
+		EmitTo(w2, e, attackEvent{Attacker: e, Damage: 1})
+	}, nil)
+	defer view.Destroy()
+	// the events are fired (and encoded) while the codecs are locked
+	rec := w2.RecordEvents()
+	assert.NoError(t, w2.UnmarshalFrom(saved))
+	assert.NoError(t, w2.ApplyDelta(Diff(s1, s2)))
+	log := rec.Stop()
+	assert.NoError(t, rec.Err())
+	assert.Equal(t, 2, len(log.Events))
+	id := w.EntityUUID(e2)
+	assert.Equal(t, id, log.Events[1].Entity)
+	assert.Equal(t, map[string]interface{}{
+		"Attacker": id.String(),
+		"Damage":   1.0,
+	}, log.Events[1].Data)
+}
+
+func TestEventRecordSystems(t *testing.T) {
+	w := NewWorld()
+	sys := NewSystem[Position](0, w)
+	sys.Run = func(view *View[Position]) {
+		w.FireEvent("test.tick", nil)
+	}
+	ticks := 0
+	w.OnEvent("test.tick", func(e Event) {
+		ticks++
+	})
+	rec := w.RecordEvents()
+	w.FireEvent("test.tick", nil)
+	w.Step()
+	w.Step()
+	log := rec.Stop()
+	assert.Equal(t, 3, ticks)
+	// the events fired by the system happen again when the world is stepped
+	assert.Equal(t, 1, len(log.Events))
+	assert.Equal(t, uint64(0), log.Events[0].Tick)
+}
@@ -0,0 +1,116 @@
+package ecs
+
+// EventQueue[T EventType] is a double buffered queue of events of the type T.
+// Events written during a tick can be read by the systems that run after the
+// writer (in the same tick) and by all the systems of the next tick. The
+// buffers are swapped once per frame, at the beginning of World.Step (or by
+// World.UpdateEvents), so an event is dropped after two frames.
+type EventQueue[T EventType] struct {
+	prev    []T
+	curr    []T
+	prevSeq uint64 // sequence number of prev[0]
+	currSeq uint64 // sequence number of curr[0]
+}
+
+// Write adds an event to the queue.
+func (q *EventQueue[T]) Write(v T) {
+	q.curr = append(q.curr, v)
+}
+
+// Each iterates (in insertion order) all the events of the queue. Events
+// written while iterating are not included.
+func (q *EventQueue[T]) Each(fn func(v T)) {
+	prev, curr := q.prev, q.curr
+	for i := range prev {
+		fn(prev[i])
+	}
+	for i := range curr {
+		fn(curr[i])
+	}
+}
+
+// Len returns the number of events in the queue.
+func (q *EventQueue[T]) Len() int {
+	return len(q.prev) + len(q.curr)
+}
+
+// NewReader returns a reader that reads each event of the queue only once.
+// The reader starts at the oldest event of the queue.
+func (q *EventQueue[T]) NewReader() *EventReader[T] {
+	return &EventReader[T]{
+		queue: q,
+		next:  q.prevSeq,
+	}
+}
+
+func (q *EventQueue[T]) swap() {
+	var zv T
+	for i := range q.prev {
+		q.prev[i] = zv
+	}
+	q.prevSeq = q.currSeq
+	q.prev, q.curr = q.curr, q.prev[:0]
+	q.currSeq = q.prevSeq + uint64(len(q.prev))
+}
+
+// EventReader[T EventType] reads the events of an EventQueue[T] that weren't
+// read yet by this reader.
+type EventReader[T EventType] struct {
+	queue *EventQueue[T]
+	next  uint64
+}
+
+// Read iterates (in insertion order) all the unread events. Events written
+// while reading are left for the next call.
+func (r *EventReader[T]) Read(fn func(v T)) {
+	q := r.queue
+	prev, curr := q.prev, q.curr
+	if r.next < q.prevSeq {
+		// the reader missed events that were already dropped
+		r.next = q.prevSeq
+	}
+	for i := r.next - q.prevSeq; i < uint64(len(prev)); i++ {
+		fn(prev[i])
+	}
+	start := q.currSeq
+	if r.next > start {
+		start = r.next
+	}
+	for i := start - q.currSeq; i < uint64(len(curr)); i++ {
+		fn(curr[i])
+	}
+	r.next = q.currSeq + uint64(len(curr))
+}
+
+// Len returns the number of unread events.
+func (r *EventReader[T]) Len() int {
+	q := r.queue
+	next := r.next
+	if next < q.prevSeq {
+		next = q.prevSeq
+	}
+	return int(q.currSeq + uint64(len(q.curr)) - next)
+}
+
+type eventQueueSwapper interface {
+	swap()
+}
+
+// GetEventQueue returns the event queue of the type T of a world.
+func GetEventQueue[T EventType](w *World) *EventQueue[T] {
+	if w.eventQueues == nil {
+		w.eventQueues = make(map[string]eventQueueSwapper)
+	}
+	var zv T
+	if q, ok := w.eventQueues[zv.Pkg()]; ok {
+		return q.(*EventQueue[T])
+	}
+	q := &EventQueue[T]{}
+	w.eventQueues[zv.Pkg()] = q
+	return q
+}
+
+// WriteEvent adds the event v to the event queue of T (see EventQueue).
+func WriteEvent[T EventType](w *World, v T) {
+	GetEventQueue[T](w).Write(v)
+}
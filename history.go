@@ -0,0 +1,298 @@
+package ecs
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var errHistoryReplaying = errors.New("cannot undo or redo while undoing or redoing")
+
+type historyOpKind int
+
+const (
+	historySpawn historyOpKind = iota
+	historyDespawn
+	historyComponent
+)
+
+// historyOp is a recorded operation. Entity IDs are never reused, so a
+// removed entity is restored with its ID (and UUID), keeping the references
+// to it valid.
+type historyOp struct {
+	kind      historyOpKind
+	entity    Entity
+	uuid      uuid.UUID         // zero if the entity had no UUID
+	name      string            // entity name (despawn)
+	relations []historyRelation // relations from or to the entity (despawn)
+	component string
+	before    interface{} // nil if the component was absent
+	after     interface{} // nil if the component was removed
+}
+
+type historyRelation struct {
+	store          IRelationStore
+	source, target Entity
+	data           interface{}
+}
+
+type historyGroup struct {
+	label string
+	ops   []historyOp
+}
+
+// History records the changes made to a world with Set (and the other
+// ComponentStore.Replace paths), RemoveComponent, NewEntity and Remove, so
+// they can be undone and redone. Changes made with Apply, SetName, Relate and
+// Unrelate are not recorded; undoing the removal of an entity restores it
+// exactly (components, name and relations). Only one history can be attached
+// to a world at a time.
+type History struct {
+	// Limit is the maximum number of undo groups kept (0 is unlimited).
+	Limit int
+
+	world     *World
+	undo      []*historyGroup
+	redo      []*historyGroup
+	current   *historyGroup
+	depth     int
+	replaying bool
+}
+
+// NewHistory creates a history and starts recording the changes of w. The
+// previous history of w (if any) is detached.
+func NewHistory(w *World) *History {
+	h := &History{
+		world: w,
+		undo:  make([]*historyGroup, 0),
+		redo:  make([]*historyGroup, 0),
+	}
+	w.history = h
+	return h
+}
+
+// Close stops recording.
+func (h *History) Close() {
+	if h.world.history == h {
+		h.world.history = nil
+	}
+}
+
+// Begin starts a group of changes that are undone and redone together. Groups
+// can be nested; the outermost group is recorded when it ends. Changes made
+// outside of a group are recorded as a group of their own.
+func (h *History) Begin(label string) {
+	h.depth++
+	if h.depth == 1 {
+		h.current = &historyGroup{
+			label: label,
+			ops:   make([]historyOp, 0),
+		}
+	}
+}
+
+// End ends a group of changes (see Begin).
+func (h *History) End() {
+	if h.depth == 0 {
+		return
+	}
+	h.depth--
+	if h.depth == 0 {
+		h.commit(h.current)
+		h.current = nil
+	}
+}
+
+// CanUndo returns true if there are changes to undo.
+func (h *History) CanUndo() bool {
+	return len(h.undo) > 0
+}
+
+// CanRedo returns true if there are undone changes to redo.
+func (h *History) CanRedo() bool {
+	return len(h.redo) > 0
+}
+
+// UndoLabel returns the label of the next group to undo.
+func (h *History) UndoLabel() string {
+	if len(h.undo) == 0 {
+		return ""
+	}
+	return h.undo[len(h.undo)-1].label
+}
+
+// RedoLabel returns the label of the next group to redo.
+func (h *History) RedoLabel() string {
+	if len(h.redo) == 0 {
+		return ""
+	}
+	return h.redo[len(h.redo)-1].label
+}
+
+// Clear drops all the recorded changes.
+func (h *History) Clear() {
+	h.undo = h.undo[:0]
+	h.redo = h.redo[:0]
+}
+
+// Undo reverts the last group of changes.
+func (h *History) Undo() error {
+	if h.replaying {
+		return errHistoryReplaying
+	}
+	if len(h.undo) == 0 {
+		return nil
+	}
+	g := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, g)
+	h.replaying = true
+	defer func() {
+		h.replaying = false
+	}()
+	for i := len(g.ops) - 1; i >= 0; i-- {
+		h.revert(&g.ops[i])
+	}
+	return nil
+}
+
+// Redo applies the last undone group of changes.
+func (h *History) Redo() error {
+	if h.replaying {
+		return errHistoryReplaying
+	}
+	if len(h.redo) == 0 {
+		return nil
+	}
+	g := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, g)
+	h.replaying = true
+	defer func() {
+		h.replaying = false
+	}()
+	for i := range g.ops {
+		h.apply(&g.ops[i])
+	}
+	return nil
+}
+
+func (h *History) commit(g *historyGroup) {
+	if g == nil || len(g.ops) == 0 {
+		return
+	}
+	h.undo = append(h.undo, g)
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = h.undo[len(h.undo)-h.Limit:]
+	}
+	h.redo = h.redo[:0]
+}
+
+func (h *History) record(op historyOp) {
+	if h.current != nil {
+		h.current.ops = append(h.current.ops, op)
+		return
+	}
+	h.commit(&historyGroup{
+		ops: []historyOp{op},
+	})
+}
+
+func (h *History) recordSpawn(e Entity) {
+	h.record(historyOp{
+		kind:   historySpawn,
+		entity: e,
+	})
+}
+
+// recordDespawn must be called before the relations of e are removed
+func (h *History) recordDespawn(e Entity) {
+	op := historyOp{
+		kind:   historyDespawn,
+		entity: e,
+		uuid:   h.world.entityIDs[e],
+		name:   h.world.Name(e),
+	}
+	for _, s := range h.world.relations {
+		s.entityRelations(e, func(source, target Entity, d interface{}) {
+			op.relations = append(op.relations, historyRelation{s, source, target, d})
+		})
+	}
+	h.record(op)
+}
+
+// recordComponent records a component change. before and after are nil if
+// the component is absent. The values are shallow copies of the component
+// data.
+func (h *History) recordComponent(e Entity, component string, before, after interface{}) {
+	h.record(historyOp{
+		kind:      historyComponent,
+		entity:    e,
+		component: component,
+		before:    before,
+		after:     after,
+	})
+}
+
+func (h *History) revert(op *historyOp) {
+	switch op.kind {
+	case historySpawn:
+		// keep the UUID the entity got after being spawned
+		op.uuid = h.world.entityIDs[op.entity]
+		h.world.Remove(op.entity)
+	case historyDespawn:
+		h.world.restoreEntity(op.entity, op.uuid)
+		if op.name != "" {
+			h.world.names.set(op.entity, op.name)
+		}
+		for _, r := range op.relations {
+			r.store.dataRelate(r.source, r.target, r.data)
+		}
+	case historyComponent:
+		h.setComponent(op.entity, op.component, op.before)
+	}
+}
+
+func (h *History) apply(op *historyOp) {
+	switch op.kind {
+	case historySpawn:
+		h.world.restoreEntity(op.entity, op.uuid)
+	case historyDespawn:
+		h.world.Remove(op.entity)
+	case historyComponent:
+		h.setComponent(op.entity, op.component, op.after)
+	}
+}
+
+func (h *History) setComponent(e Entity, component string, d interface{}) {
+	store := h.world.components[component]
+	if store == nil {
+		return
+	}
+	if d == nil {
+		store.Remove(e)
+		return
+	}
+	store.dataReplace(e, d)
+}
+
+// recording returns the history of the world if the changes are being
+// recorded.
+func (w *World) recording() *History {
+	if w.history == nil || w.history.replaying {
+		return nil
+	}
+	return w.history
+}
+
+// restoreEntity adds back a removed entity (with its UUID, if not zero).
+func (w *World) restoreEntity(e Entity, id uuid.UUID) {
+	if i, ok := getEntityIndex(w.entities, e); !ok {
+		w.entities = Insert(w.entities, i, e)
+		w.invalidateRelevance(e)
+	}
+	if id != (uuid.UUID{}) {
+		w.entityIDs[e] = id
+		w.entityUUIDs[id] = e
+	}
+}
@@ -0,0 +1,111 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	w := NewWorld()
+	h := NewHistory(w)
+	getTarget := func(e Entity) (v diffTarget) {
+		Apply(w, e, func(d *diffTarget) { v = *d })
+		return
+	}
+
+	h.Begin("spawn")
+	ship := w.NewEntity()
+	station := w.NewEntity()
+	assert.NoError(t, w.SetName(station, "station"))
+	Set(w, station, registeredComponent{Name: "alpha"})
+	Set(w, ship, diffTarget{Target: station, Label: "dock"})
+	h.End()
+	Set(w, ship, diffTarget{Target: station, Label: "undock"})
+	assert.True(t, h.CanUndo())
+	assert.Equal(t, "", h.UndoLabel())
+
+	// undo a replace
+	assert.NoError(t, h.Undo())
+	assert.Equal(t, "dock", getTarget(ship).Label)
+	assert.True(t, h.CanRedo())
+	assert.NoError(t, h.Redo())
+	assert.Equal(t, "undock", getTarget(ship).Label)
+
+	// undo a removal: the entity keeps its ID and UUID
+	stationID := w.EntityUUID(station)
+	w.Remove(station)
+	assert.False(t, Contains[registeredComponent](w, station))
+	assert.NoError(t, h.Undo())
+	restored, ok := w.EntityByUUID(stationID)
+	assert.True(t, ok)
+	assert.Equal(t, station, restored)
+	assert.Equal(t, "station", w.Name(station))
+	assert.True(t, Contains[registeredComponent](w, station))
+
+	// a new change drops the redo stack
+	RemoveComponent[diffTarget](w, ship)
+	assert.False(t, h.CanRedo())
+	assert.NoError(t, h.Undo())
+	assert.Equal(t, "undock", getTarget(ship).Label)
+
+	// undo everything
+	assert.NoError(t, h.Undo())
+	assert.Equal(t, "spawn", h.UndoLabel())
+	assert.NoError(t, h.Undo())
+	assert.False(t, h.CanUndo())
+	assert.Equal(t, 0, len(w.entities))
+
+	assert.NoError(t, h.Redo())
+	assert.Equal(t, "spawn", h.UndoLabel())
+	assert.Equal(t, []Entity{ship, station}, w.entities)
+	assert.Equal(t, "dock", getTarget(ship).Label)
+	assert.Equal(t, station, getTarget(ship).Target)
+	e, _ := w.EntityByUUID(stationID)
+	assert.Equal(t, station, e)
+
+	h.Close()
+	w.NewEntity()
+	assert.Equal(t, "spawn", h.UndoLabel())
+}
+
+func TestHistoryRemoveMany(t *testing.T) {
+	w := NewWorld()
+	ents := w.NewEntities(3)
+	SetBatch(w, ents, []registeredComponent{{"a"}, {"b"}, {"c"}})
+	h := NewHistory(w)
+	h.Limit = 1
+	w.RemoveMany(ents[:2])
+	w.Clear()
+	assert.Equal(t, 0, GetComponentStore[registeredComponent](w).Len())
+	assert.NoError(t, h.Undo())
+	assert.False(t, h.CanUndo())
+	assert.Equal(t, 1, GetComponentStore[registeredComponent](w).Len())
+	assert.Equal(t, 1, len(w.entities))
+}
+
+func TestHistoryRemoveRelations(t *testing.T) {
+	w := NewWorld()
+	ents := w.NewEntities(3)
+	player, ship, sword := ents[0], ents[1], ents[2]
+	assert.NoError(t, Relate(w, ship, player, ownedBy{Since: 1}))
+	assert.NoError(t, Relate(w, sword, player, ownedBy{Since: 2}))
+	assert.NoError(t, Relate(w, player, ship, dockedAt{}))
+	h := NewHistory(w)
+
+	w.Remove(player)
+	assert.Equal(t, 0, GetRelationStore[ownedBy](w).Len())
+	assert.NoError(t, h.Undo())
+	assert.Equal(t, []Entity{ship, sword}, Sources[ownedBy](w, player))
+	d, _ := GetRelation[ownedBy](w, sword, player)
+	assert.Equal(t, 2, d.Since)
+	assert.True(t, HasRelation[dockedAt](w, player, ship))
+
+	// both ends removed at once
+	w.RemoveMany([]Entity{player, ship})
+	assert.NoError(t, h.Undo())
+	assert.Equal(t, 2, GetRelationStore[ownedBy](w).Len())
+	assert.Equal(t, 1, GetRelationStore[dockedAt](w).Len())
+	assert.NoError(t, h.Redo())
+	assert.Equal(t, 0, GetRelationStore[ownedBy](w).Len())
+}
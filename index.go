@@ -0,0 +1,297 @@
+package ecs
+
+import "sort"
+
+// Ordered is a constraint for the key types of an OrderedIndex.
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~string
+}
+
+// componentIndexer is an index of a component store. It is updated by the
+// store on Replace, Apply and Remove.
+type componentIndexer[T ComponentType] interface {
+	indexSet(e Entity, d *T)
+	indexRemove(e Entity)
+	indexRebuild()
+}
+
+// Index is a secondary index of the component type T, keyed by K. It is
+// maintained by the component store on Set (Replace), Apply and Remove.
+// Changes made through views or pointers are not tracked; call Update or
+// Rebuild after them. Entities with a NaN key (a key that is not equal to
+// itself) are not indexed, since NaN can't be looked up.
+type Index[T ComponentType, K comparable] struct {
+	store    *ComponentStore[T]
+	key      func(d *T) K
+	keys     map[Entity]K
+	entities map[K][]Entity // sorted
+}
+
+// NewIndex creates an index of the component type T with the key returned
+// by the key function.
+func NewIndex[T ComponentType, K comparable](w *World, key func(d *T) K) *Index[T, K] {
+	idx := &Index[T, K]{
+		store: GetComponentStore[T](w),
+		key:   key,
+	}
+	idx.store.indexes = append(idx.store.indexes, idx)
+	idx.Rebuild()
+	return idx
+}
+
+// Lookup returns the entities (sorted) with the key k.
+func (idx *Index[T, K]) Lookup(k K) []Entity {
+	ents := idx.entities[k]
+	result := make([]Entity, len(ents))
+	copy(result, ents)
+	return result
+}
+
+// First returns the first entity with the key k.
+func (idx *Index[T, K]) First(k K) (Entity, bool) {
+	if ents := idx.entities[k]; len(ents) > 0 {
+		return ents[0], true
+	}
+	return 0, false
+}
+
+// Count returns the number of entities with the key k.
+func (idx *Index[T, K]) Count(k K) int {
+	return len(idx.entities[k])
+}
+
+// Update updates the key of e with its current component data.
+func (idx *Index[T, K]) Update(e Entity) {
+	if x := idx.store.ptr(e); x != nil {
+		idx.indexSet(e, x)
+	} else {
+		idx.indexRemove(e)
+	}
+}
+
+// Rebuild rebuilds the index with all the component data of the store.
+func (idx *Index[T, K]) Rebuild() {
+	idx.keys = make(map[Entity]K)
+	idx.entities = make(map[K][]Entity)
+	all := idx.store.all()
+	for i := range all {
+		idx.indexSet(all[i].Entity, &all[i].Data)
+	}
+}
+
+// Destroy stops maintaining the index.
+func (idx *Index[T, K]) Destroy() {
+	idx.store.removeIndex(idx)
+	idx.keys = nil
+	idx.entities = nil
+}
+
+func (idx *Index[T, K]) indexSet(e Entity, d *T) {
+	k := idx.key(d)
+	if isNaN(k) {
+		idx.indexRemove(e)
+		return
+	}
+	if old, ok := idx.keys[e]; ok {
+		if old == k {
+			return
+		}
+		idx.removeKey(e, old)
+	}
+	idx.keys[e] = k
+	ents := idx.entities[k]
+	i, _ := getEntityIndex(ents, e)
+	idx.entities[k] = Insert(ents, i, e)
+}
+
+func (idx *Index[T, K]) indexRemove(e Entity) {
+	if old, ok := idx.keys[e]; ok {
+		idx.removeKey(e, old)
+		delete(idx.keys, e)
+	}
+}
+
+func (idx *Index[T, K]) indexRebuild() {
+	idx.Rebuild()
+}
+
+func (idx *Index[T, K]) removeKey(e Entity, k K) {
+	ents := idx.entities[k]
+	if i, ok := getEntityIndex(ents, e); ok {
+		ents = append(ents[:i], ents[i+1:]...)
+	}
+	if len(ents) == 0 {
+		delete(idx.entities, k)
+		return
+	}
+	idx.entities[k] = ents
+}
+
+type orderedIndexEntry[K Ordered] struct {
+	key    K
+	entity Entity
+}
+
+// OrderedIndex is a secondary index of the component type T with ordered
+// keys. Besides exact lookups, it supports range scans (see Range). Like
+// Index, changes made through views or pointers are not tracked and entities
+// with a NaN key are not indexed (NaN is not ordered).
+type OrderedIndex[T ComponentType, K Ordered] struct {
+	store   *ComponentStore[T]
+	key     func(d *T) K
+	keys    map[Entity]K
+	entries []orderedIndexEntry[K] // sorted by key and entity
+}
+
+// NewOrderedIndex creates an ordered index of the component type T with the
+// key returned by the key function.
+func NewOrderedIndex[T ComponentType, K Ordered](w *World, key func(d *T) K) *OrderedIndex[T, K] {
+	idx := &OrderedIndex[T, K]{
+		store: GetComponentStore[T](w),
+		key:   key,
+	}
+	idx.store.indexes = append(idx.store.indexes, idx)
+	idx.Rebuild()
+	return idx
+}
+
+// Lookup returns the entities (sorted) with the key k.
+func (idx *OrderedIndex[T, K]) Lookup(k K) []Entity {
+	result := make([]Entity, 0)
+	idx.Range(k, k, func(e Entity, _ K) bool {
+		result = append(result, e)
+		return true
+	})
+	return result
+}
+
+// First returns the first entity with the key k.
+func (idx *OrderedIndex[T, K]) First(k K) (Entity, bool) {
+	i := idx.search(k, 0)
+	if i < len(idx.entries) && idx.entries[i].key == k {
+		return idx.entries[i].entity, true
+	}
+	return 0, false
+}
+
+// Range calls fn for each entity with a key between min and max (inclusive),
+// in key order. It stops if fn returns false.
+func (idx *OrderedIndex[T, K]) Range(min, max K, fn func(e Entity, k K) bool) {
+	if isNaN(min) || isNaN(max) {
+		return
+	}
+	for i := idx.search(min, 0); i < len(idx.entries); i++ {
+		entry := idx.entries[i]
+		if entry.key > max {
+			return
+		}
+		if !fn(entry.entity, entry.key) {
+			return
+		}
+	}
+}
+
+// Len returns the number of indexed entities.
+func (idx *OrderedIndex[T, K]) Len() int {
+	return len(idx.entries)
+}
+
+// Update updates the key of e with its current component data.
+func (idx *OrderedIndex[T, K]) Update(e Entity) {
+	if x := idx.store.ptr(e); x != nil {
+		idx.indexSet(e, x)
+	} else {
+		idx.indexRemove(e)
+	}
+}
+
+// Rebuild rebuilds the index with all the component data of the store.
+func (idx *OrderedIndex[T, K]) Rebuild() {
+	all := idx.store.all()
+	idx.keys = make(map[Entity]K, len(all))
+	idx.entries = make([]orderedIndexEntry[K], 0, len(all))
+	for i := range all {
+		k := idx.key(&all[i].Data)
+		if isNaN(k) {
+			continue
+		}
+		idx.keys[all[i].Entity] = k
+		idx.entries = append(idx.entries, orderedIndexEntry[K]{k, all[i].Entity})
+	}
+	sort.Slice(idx.entries, func(i, j int) bool {
+		return idx.entries[i].less(idx.entries[j])
+	})
+}
+
+// Destroy stops maintaining the index.
+func (idx *OrderedIndex[T, K]) Destroy() {
+	idx.store.removeIndex(idx)
+	idx.keys = nil
+	idx.entries = nil
+}
+
+// search returns the index of the first entry >= (k, e)
+func (idx *OrderedIndex[T, K]) search(k K, e Entity) int {
+	v := orderedIndexEntry[K]{k, e}
+	return sort.Search(len(idx.entries), func(i int) bool {
+		return !idx.entries[i].less(v)
+	})
+}
+
+func (idx *OrderedIndex[T, K]) indexSet(e Entity, d *T) {
+	k := idx.key(d)
+	if isNaN(k) {
+		idx.indexRemove(e)
+		return
+	}
+	if old, ok := idx.keys[e]; ok {
+		if old == k {
+			return
+		}
+		idx.removeEntry(e, old)
+	}
+	idx.keys[e] = k
+	idx.entries = Insert(idx.entries, idx.search(k, e), orderedIndexEntry[K]{k, e})
+}
+
+func (idx *OrderedIndex[T, K]) indexRemove(e Entity) {
+	if old, ok := idx.keys[e]; ok {
+		idx.removeEntry(e, old)
+		delete(idx.keys, e)
+	}
+}
+
+func (idx *OrderedIndex[T, K]) indexRebuild() {
+	idx.Rebuild()
+}
+
+func (idx *OrderedIndex[T, K]) removeEntry(e Entity, k K) {
+	i := idx.search(k, e)
+	if i < len(idx.entries) && idx.entries[i].entity == e {
+		idx.entries = append(idx.entries[:i], idx.entries[i+1:]...)
+	}
+}
+
+func (a orderedIndexEntry[K]) less(b orderedIndexEntry[K]) bool {
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.entity < b.entity
+}
+
+// isNaN returns true if k is not equal to itself (a NaN float, or a value
+// that contains one).
+func isNaN[K comparable](k K) bool {
+	return k != k
+}
+
+func (c *ComponentStore[T]) removeIndex(idx componentIndexer[T]) {
+	for i, v := range c.indexes {
+		if v == idx {
+			c.indexes = append(c.indexes[:i], c.indexes[i+1:]...)
+			return
+		}
+	}
+}
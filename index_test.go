@@ -0,0 +1,144 @@
+package ecs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type indexName struct {
+	Value string
+}
+
+func (indexName) Pkg() string {
+	return "test.indexName"
+}
+
+type indexTeam struct {
+	ID    int
+	Score float64
+}
+
+func (indexTeam) Pkg() string {
+	return "test.indexTeam"
+}
+
+func TestIndex(t *testing.T) {
+	w := NewEmptyWorld()
+	ents := w.NewEntities(6)
+	Set(w, ents[0], indexName{"boss"})
+	byName := NewIndex(w, func(n *indexName) string {
+		return n.Value
+	})
+	Set(w, ents[1], indexName{"minion"})
+	Set(w, ents[2], indexName{"minion"})
+	SetBatch(w, ents[3:], []indexName{{"a"}, {"minion"}, {"b"}})
+
+	e, ok := byName.First("boss")
+	assert.True(t, ok)
+	assert.Equal(t, ents[0], e)
+	assert.Equal(t, []Entity{ents[1], ents[2], ents[4]}, byName.Lookup("minion"))
+
+	Apply(w, ents[2], func(n *indexName) {
+		n.Value = "boss"
+	})
+	assert.Equal(t, []Entity{ents[0], ents[2]}, byName.Lookup("boss"))
+	assert.True(t, RemoveComponent[indexName](w, ents[0]))
+	w.RemoveMany([]Entity{ents[1]})
+	e, _ = byName.First("boss")
+	assert.Equal(t, ents[2], e)
+	assert.Equal(t, []Entity{ents[4]}, byName.Lookup("minion"))
+
+	GetComponentStore[indexName](w).Apply(ents[4], func(n *indexName) {})
+	_, ok = byName.First("nobody")
+	assert.False(t, ok)
+	assert.Equal(t, 0, byName.Count("nobody"))
+
+	byName.Destroy()
+	Set(w, ents[5], indexName{"boss"})
+	assert.Empty(t, GetComponentStore[indexName](w).indexes)
+}
+
+func TestOrderedIndex(t *testing.T) {
+	w := NewWorldWithOptions(WorldOptions{Storage: StorageArchetype, Empty: true})
+	ents := w.NewEntities(5)
+	for i, e := range ents {
+		Set(w, e, indexTeam{ID: i % 3, Score: float64(10 - i)})
+	}
+	byTeam := NewOrderedIndex(w, func(t *indexTeam) int {
+		return t.ID
+	})
+	byScore := NewOrderedIndex(w, func(t *indexTeam) float64 {
+		return t.Score
+	})
+	assert.Equal(t, []Entity{ents[1], ents[4]}, byTeam.Lookup(1))
+
+	scores := make([]float64, 0)
+	byScore.Range(6.5, 9, func(e Entity, k float64) bool {
+		scores = append(scores, k)
+		return true
+	})
+	assert.Equal(t, []float64{7, 8, 9}, scores)
+
+	Set(w, ents[0], indexTeam{ID: 2, Score: 0})
+	teams := make([]Entity, 0)
+	byTeam.Range(1, 2, func(e Entity, k int) bool {
+		teams = append(teams, e)
+		return len(teams) < 3
+	})
+	assert.Equal(t, []Entity{ents[1], ents[4], ents[0]}, teams)
+	e, ok := byScore.First(0)
+	assert.True(t, ok)
+	assert.Equal(t, ents[0], e)
+
+	// changes through views are not tracked until Update
+	NewView[indexTeam](w, nil, nil).Each(func(e Entity, d *indexTeam) {
+		d.ID = 7
+	})
+	assert.Equal(t, 0, len(byTeam.Lookup(7)))
+	byTeam.Update(ents[3])
+	assert.Equal(t, []Entity{ents[3]}, byTeam.Lookup(7))
+	byTeam.Rebuild()
+	assert.Equal(t, 5, len(byTeam.Lookup(7)))
+	w.Remove(ents[3])
+	assert.Equal(t, 4, byTeam.Len())
+	assert.Equal(t, 4, byScore.Len())
+}
+
+func TestIndexNaN(t *testing.T) {
+	w := NewEmptyWorld()
+	ents := w.NewEntities(4)
+	for i, e := range ents {
+		Set(w, e, indexTeam{ID: i, Score: float64(i)})
+	}
+	byScore := NewIndex(w, func(t *indexTeam) float64 {
+		return t.Score
+	})
+	ordered := NewOrderedIndex(w, func(t *indexTeam) float64 {
+		return t.Score
+	})
+	Set(w, ents[1], indexTeam{Score: math.NaN()})
+	Set(w, ents[2], indexTeam{Score: math.NaN()})
+	assert.Equal(t, 2, len(byScore.keys))
+	assert.Equal(t, 2, ordered.Len())
+	assert.Equal(t, 0, len(byScore.Lookup(math.NaN())))
+	assert.Equal(t, 0, len(ordered.Lookup(math.NaN())))
+
+	scores := make([]float64, 0)
+	ordered.Range(0, 10, func(e Entity, k float64) bool {
+		scores = append(scores, k)
+		return true
+	})
+	assert.Equal(t, []float64{0, 3}, scores)
+
+	// the entities are indexed again with a valid key
+	Set(w, ents[2], indexTeam{Score: 1})
+	w.Remove(ents[1])
+	assert.Equal(t, []Entity{ents[2]}, byScore.Lookup(1))
+	assert.Equal(t, []Entity{ents[2]}, ordered.Lookup(1))
+	assert.Equal(t, 3, len(byScore.entities))
+	byScore.Rebuild()
+	ordered.Rebuild()
+	assert.Equal(t, 3, ordered.Len())
+}
@@ -0,0 +1,328 @@
+package ecs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errInspectorNotLocal = errors.New("the inspector can only listen on a loopback address")
+
+// Inspector is an HTTP debug inspector of a running world. It serves:
+//
+//	GET   /entities                         entities with names and components
+//	GET   /entities/{id}                    components of an entity (JSON)
+//	PATCH /entities/{id}/components/{name}  merges JSON into a component
+//	GET   /components                       component types and counts
+//	GET   /systems                          systems, priorities, flags and timings
+//	GET   /listeners                        event listeners
+//
+// The entity id can be the entity ID or its UUID. Since the world is not
+// thread safe, the requests are queued and served by the world goroutine in
+// World.Step (or Poll).
+type Inspector struct {
+	// Timeout is the maximum time a request waits to be served.
+	Timeout time.Duration
+
+	world   *World
+	jobs    chan func()
+	timings map[int]*systemTiming
+	lock    sync.Mutex
+	server  *http.Server
+}
+
+type systemTiming struct {
+	last  time.Duration
+	total time.Duration
+	runs  uint64
+}
+
+type inspectorResult struct {
+	status int
+	body   []byte
+}
+
+// NewInspector creates an inspector of the world w.
+func NewInspector(w *World) *Inspector {
+	i := &Inspector{
+		Timeout: time.Second * 5,
+		world:   w,
+		jobs:    make(chan func(), 64),
+		timings: make(map[int]*systemTiming),
+	}
+	w.inspector = i
+	return i
+}
+
+// Close detaches the inspector from the world and stops the server started by
+// ListenAndServe. It must be called by the world goroutine.
+func (i *Inspector) Close() {
+	if i.world.inspector == i {
+		i.world.inspector = nil
+	}
+	i.lock.Lock()
+	srv := i.server
+	i.server = nil
+	i.lock.Unlock()
+	if srv != nil {
+		_ = srv.Close()
+	}
+}
+
+// Poll serves the queued requests. It is called by World.Step and
+// World.StepF; call it periodically if the world is not being stepped.
+func (i *Inspector) Poll() {
+	for {
+		select {
+		case job := <-i.jobs:
+			job()
+		default:
+			return
+		}
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves the inspector
+// until Close is called (then it returns http.ErrServerClosed). The address
+// must be a loopback address (e.g. "localhost:6061").
+func (i *Inspector) ListenAndServe(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return errInspectorNotLocal
+		}
+	}
+	srv := &http.Server{Addr: addr, Handler: i}
+	i.lock.Lock()
+	i.server = srv
+	i.lock.Unlock()
+	return srv.ListenAndServe()
+}
+
+func (i *Inspector) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+	var job func() inspectorResult
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "entities":
+		job = i.entities
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "entities":
+		job = func() inspectorResult {
+			return i.entity(parts[1])
+		}
+	case (r.Method == http.MethodPatch || r.Method == http.MethodPut) && len(parts) == 4 &&
+		parts[0] == "entities" && parts[2] == "components":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job = func() inspectorResult {
+			return i.editComponent(parts[1], parts[3], body)
+		}
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "components":
+		job = func() inspectorResult {
+			return i.json(i.world.ComponentTypes())
+		}
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "systems":
+		job = i.systems
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "listeners":
+		job = func() inspectorResult {
+			return i.json(i.world.Listeners())
+		}
+	default:
+		http.NotFound(rw, r)
+		return
+	}
+	res := i.do(r, job)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(res.status)
+	_, _ = rw.Write(res.body)
+}
+
+// do queues the job and waits for its result
+func (i *Inspector) do(r *http.Request, job func() inspectorResult) inspectorResult {
+	done := make(chan inspectorResult, 1)
+	timeout := time.NewTimer(i.Timeout)
+	defer timeout.Stop()
+	select {
+	case i.jobs <- func() { done <- job() }:
+	case <-timeout.C:
+		return inspectorError(http.StatusServiceUnavailable, errors.New("the world is not being stepped"))
+	case <-r.Context().Done():
+		return inspectorError(http.StatusServiceUnavailable, r.Context().Err())
+	}
+	select {
+	case res := <-done:
+		return res
+	case <-timeout.C:
+		return inspectorError(http.StatusServiceUnavailable, errors.New("the world is not being stepped"))
+	case <-r.Context().Done():
+		return inspectorError(http.StatusServiceUnavailable, r.Context().Err())
+	}
+}
+
+func (i *Inspector) recordTiming(id int, d time.Duration) {
+	t := i.timings[id]
+	if t == nil {
+		t = &systemTiming{}
+		i.timings[id] = t
+	}
+	t.last = d
+	t.total += d
+	t.runs++
+}
+
+// json encodes v (with the world as the encoder world of entities). Entities
+// without UUIDs don't get one, so the requests don't change the world.
+func (i *Inspector) json(v interface{}) inspectorResult {
+	encoderMutex.Lock()
+	defer encoderMutex.Unlock()
+	setEncoderWorld(i.world)
+	defer setEncoderWorld(nil)
+	encoderReadOnly = true
+	defer func() {
+		encoderReadOnly = false
+	}()
+	b, err := json.Marshal(v)
+	if err != nil {
+		return inspectorError(http.StatusInternalServerError, err)
+	}
+	return inspectorResult{http.StatusOK, b}
+}
+
+func (i *Inspector) findEntity(id string) (Entity, bool) {
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		_, ok := getEntityIndex(i.world.entities, Entity(n))
+		return Entity(n), ok
+	}
+	if u, err := uuid.Parse(id); err == nil {
+		return i.world.EntityByUUID(u)
+	}
+	return 0, false
+}
+
+type inspectorEntity struct {
+	ID         uint64                 `json:"id"`
+	UUID       *uuid.UUID             `json:"uuid,omitempty"`
+	Name       string                 `json:"name,omitempty"`
+	Label      string                 `json:"label"`
+	Components []string               `json:"components,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+func (i *Inspector) entities() inspectorResult {
+	w := i.world
+	result := make([]inspectorEntity, 0, len(w.entities))
+	for _, e := range w.entities {
+		names := make([]string, 0)
+		for _, c := range w.ComponentsOf(e) {
+			names = append(names, c.Name)
+		}
+		result = append(result, inspectorEntity{
+			ID:         uint64(e),
+			UUID:       i.entityUUID(e),
+			Name:       w.Name(e),
+			Label:      w.EntityLabel(e),
+			Components: names,
+		})
+	}
+	return i.json(result)
+}
+
+// entityUUID returns the UUID of e, or nil if it has none (without assigning
+// one)
+func (i *Inspector) entityUUID(e Entity) *uuid.UUID {
+	if id, ok := i.world.entityIDs[e]; ok {
+		return &id
+	}
+	return nil
+}
+
+func (i *Inspector) entity(id string) inspectorResult {
+	w := i.world
+	e, ok := i.findEntity(id)
+	if !ok {
+		return inspectorError(http.StatusNotFound, fmt.Errorf("entity %s not found", id))
+	}
+	data := make(map[string]interface{})
+	for _, c := range w.ComponentsOf(e) {
+		data[c.Name] = c.Data
+	}
+	return i.json(inspectorEntity{
+		ID:    uint64(e),
+		UUID:  i.entityUUID(e),
+		Name:  w.Name(e),
+		Label: w.EntityLabel(e),
+		Data:  data,
+	})
+}
+
+func (i *Inspector) editComponent(id, name string, body []byte) inspectorResult {
+	w := i.world
+	e, ok := i.findEntity(id)
+	if !ok {
+		return inspectorError(http.StatusNotFound, fmt.Errorf("entity %s not found", id))
+	}
+	c := w.GetGenericComponent(name)
+	if c == nil {
+		return inspectorError(http.StatusNotFound, fmt.Errorf("component %s: %w", name, ErrUnknownComponent))
+	}
+	err := func() error {
+		defer w.lockCodec()()
+		return c.MergeJSONData(e, body)
+	}()
+	if err != nil {
+		return inspectorError(http.StatusBadRequest, err)
+	}
+	d, _ := w.ComponentOf(e, name)
+	return i.json(d)
+}
+
+type inspectorSystem struct {
+	ID       int           `json:"id"`
+	Type     string        `json:"type"`
+	Priority int           `json:"priority"`
+	Flag     int           `json:"flag"`
+	Runs     uint64        `json:"runs"`
+	Last     time.Duration `json:"last_ns"`
+	Average  time.Duration `json:"avg_ns"`
+}
+
+func (i *Inspector) systems() inspectorResult {
+	result := make([]inspectorSystem, 0, len(i.world.systems))
+	for _, sys := range i.world.systems {
+		s := inspectorSystem{
+			ID:       sys.ID(),
+			Type:     fmt.Sprintf("%T", sys),
+			Priority: sys.Priority(),
+			Flag:     sys.Flag(),
+		}
+		if t := i.timings[sys.ID()]; t != nil {
+			s.Runs = t.runs
+			s.Last = t.last
+			s.Average = t.total / time.Duration(t.runs)
+		}
+		result = append(result, s)
+	}
+	return i.json(result)
+}
+
+func inspectorError(status int, err error) inspectorResult {
+	b, _ := json.Marshal(map[string]string{
+		"error": err.Error(),
+	})
+	return inspectorResult{status, b}
+}
@@ -0,0 +1,107 @@
+package ecs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// inspect runs a request against the inspector while the world is stepped
+func inspect(w *World, i *Inspector, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		i.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return rec
+		default:
+			w.Step()
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestInspector(t *testing.T) {
+	w := NewEmptyWorld()
+	e := w.NewEntity()
+	Set(w, e, Position{X: 1, Y: 2})
+	assert.NoError(t, w.SetName(e, "player"))
+	sys := NewSystem[Position](10, w)
+	sys.Run = func(view *View[Position]) {}
+	w.OnEvent("hit", func(ev Event) {})
+	i := NewInspector(w)
+	defer i.Close()
+
+	rec := inspect(w, i, http.MethodGet, "/entities", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	ents := make([]map[string]interface{}, 0)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ents))
+	assert.Equal(t, 1, len(ents))
+	assert.Equal(t, "player", ents[0]["name"])
+	// reading doesn't assign UUIDs
+	assert.Nil(t, ents[0]["uuid"])
+	assert.Equal(t, 0, len(w.entityIDs))
+
+	rec = inspect(w, i, http.MethodPatch, "/entities/1/components/test.Position", `{"X": 5}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	Apply(w, e, func(p *Position) {
+		assert.Equal(t, Position{X: 5, Y: 2}, *p)
+	})
+
+	rec = inspect(w, i, http.MethodGet, "/entities/"+w.EntityUUID(e).String(), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"test.Position":{"X":5,"Y":2}`)
+
+	rec = inspect(w, i, http.MethodGet, "/systems", "")
+	systems := make([]inspectorSystem, 0)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &systems))
+	assert.Equal(t, 1, len(systems))
+	assert.Equal(t, 10, systems[0].Priority)
+	assert.Greater(t, systems[0].Runs, uint64(0))
+
+	rec = inspect(w, i, http.MethodGet, "/listeners", "")
+	assert.Contains(t, rec.Body.String(), `"Name":"hit"`)
+	rec = inspect(w, i, http.MethodGet, "/components", "")
+	assert.Contains(t, rec.Body.String(), `"Name":"test.Position","Count":1`)
+
+	assert.Equal(t, http.StatusNotFound, inspect(w, i, http.MethodGet, "/entities/99", "").Code)
+	assert.Equal(t, http.StatusBadRequest, inspect(w, i, http.MethodPatch, "/entities/1/components/test.Position", "{").Code)
+
+	// not stepped
+	i.Timeout = time.Millisecond * 10
+	rec = httptest.NewRecorder()
+	i.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/entities", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	assert.ErrorIs(t, i.ListenAndServe("0.0.0.0:0"), errInspectorNotLocal)
+}
+
+func TestInspectorClose(t *testing.T) {
+	w := NewEmptyWorld()
+	i := NewInspector(w)
+	done := make(chan error, 1)
+	go func() {
+		done <- i.ListenAndServe("127.0.0.1:0")
+	}()
+	for {
+		i.lock.Lock()
+		started := i.server != nil
+		i.lock.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	i.Close()
+	assert.ErrorIs(t, <-done, http.ErrServerClosed)
+	assert.Nil(t, w.inspector)
+}
@@ -0,0 +1,70 @@
+package ecs
+
+import "sort"
+
+// ComponentInfo is a component of an entity (see World.ComponentsOf).
+type ComponentInfo struct {
+	Name string      // the registry name (Pkg())
+	Data interface{} // a copy of the component data
+}
+
+// ComponentTypeInfo is a component type of a world (see
+// World.ComponentTypes).
+type ComponentTypeInfo struct {
+	Name    string // the registry name (Pkg())
+	Count   int    // number of entities with the component
+	Version int    // schema version (see SetComponentVersion)
+}
+
+// ComponentsOf returns the components of an entity, sorted by name.
+func (w *World) ComponentsOf(e Entity) []ComponentInfo {
+	result := make([]ComponentInfo, 0)
+	for name, c := range w.components {
+		if d := c.dataOf(e); d != nil {
+			result = append(result, ComponentInfo{
+				Name: name,
+				Data: d,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// ComponentOf returns a copy of the component data of an entity by the
+// component registry name.
+func (w *World) ComponentOf(e Entity, name string) (interface{}, bool) {
+	c, ok := w.components[name]
+	if !ok {
+		return nil, false
+	}
+	d := c.dataOf(e)
+	return d, d != nil
+}
+
+// ComponentTypes returns all the component types of the world and the ones
+// registered globally (see RegisterComponent), sorted by name.
+func (w *World) ComponentTypes() []ComponentTypeInfo {
+	counts := make(map[string]int)
+	for name, c := range w.components {
+		counts[name] = c.Len()
+	}
+	globalComponents.lock.RLock()
+	for name := range globalComponents.factories {
+		if _, ok := counts[name]; !ok {
+			counts[name] = 0
+		}
+	}
+	globalComponents.lock.RUnlock()
+	result := make([]ComponentTypeInfo, 0, len(counts))
+	for _, name := range sortedKeys(counts) {
+		result = append(result, ComponentTypeInfo{
+			Name:    name,
+			Count:   counts[name],
+			Version: ComponentVersion(name),
+		})
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponentsOf(t *testing.T) {
+	w := NewEmptyWorld()
+	e := w.NewEntity()
+	e2 := w.NewEntity()
+	Set(w, e, Rotation{Value: 3})
+	Set(w, e, Position{X: 1, Y: 2})
+	Set(w, e2, Position{X: 5})
+	Set(w, e2, sparseStatus{Stunned: true})
+
+	assert.Equal(t, []ComponentInfo{
+		{Name: "test.Position", Data: Position{X: 1, Y: 2}},
+		{Name: "test.Rotation", Data: Rotation{Value: 3}},
+	}, w.ComponentsOf(e))
+	d, ok := w.ComponentOf(e2, sparseStatus{}.Pkg())
+	assert.True(t, ok)
+	assert.Equal(t, sparseStatus{Stunned: true}, d)
+	_, ok = w.ComponentOf(e2, "test.Rotation")
+	assert.False(t, ok)
+	assert.Empty(t, w.ComponentsOf(w.NewEntity()))
+
+	types := make(map[string]int)
+	for _, v := range w.ComponentTypes() {
+		types[v.Name] = v.Count
+	}
+	assert.Equal(t, 2, types["test.Position"])
+	assert.Equal(t, 1, types["test.Rotation"])
+	assert.Equal(t, 1, types[sparseStatus{}.Pkg()])
+	count, registered := types["test.registeredComponent"]
+	assert.True(t, registered)
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,125 @@
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrNameTaken is returned by SetName when the world requires unique names
+// and another entity already has the name.
+var ErrNameTaken = errors.New("entity name already taken")
+
+// entityNames is the name registry of a world
+type entityNames struct {
+	names  map[Entity]string
+	byName map[string][]Entity // sorted
+	unique bool
+}
+
+func newEntityNames() entityNames {
+	return entityNames{
+		names:  make(map[Entity]string),
+		byName: make(map[string][]Entity),
+	}
+}
+
+func (n *entityNames) set(e Entity, name string) {
+	n.remove(e)
+	if name == "" {
+		return
+	}
+	n.names[e] = name
+	ents := n.byName[name]
+	i, _ := getEntityIndex(ents, e)
+	n.byName[name] = Insert(ents, i, e)
+}
+
+func (n *entityNames) remove(e Entity) {
+	name, ok := n.names[e]
+	if !ok {
+		return
+	}
+	delete(n.names, e)
+	ents := n.byName[name]
+	if i, ok := getEntityIndex(ents, e); ok {
+		ents = append(ents[:i], ents[i+1:]...)
+	}
+	if len(ents) == 0 {
+		delete(n.byName, name)
+		return
+	}
+	n.byName[name] = ents
+}
+
+// taken returns true if an entity other than e has the name
+func (n *entityNames) taken(name string, e Entity) bool {
+	for _, v := range n.byName[name] {
+		if v != e {
+			return true
+		}
+	}
+	return false
+}
+
+// SetName sets (or renames) the name of an entity. An empty name removes the
+// name. It returns ErrEntityNotFound if e is not in the world and, if the
+// world requires unique names (see SetUniqueNames), ErrNameTaken when another
+// entity has the name.
+func (w *World) SetName(e Entity, name string) error {
+	if !w.hasEntity(e) {
+		return fmt.Errorf("%w: %d", ErrEntityNotFound, e)
+	}
+	if name != "" && w.names.unique && w.names.taken(name, e) {
+		return fmt.Errorf("%w: %q", ErrNameTaken, name)
+	}
+	w.names.set(e, name)
+	return nil
+}
+
+// Name returns the name of an entity (or an empty string).
+func (w *World) Name(e Entity) string {
+	return w.names.names[e]
+}
+
+// EntityByName returns the first entity (lowest ID) with the name.
+func (w *World) EntityByName(name string) (Entity, bool) {
+	if ents := w.names.byName[name]; len(ents) > 0 {
+		return ents[0], true
+	}
+	return 0, false
+}
+
+// EntitiesByName returns all the entities (sorted) with the name.
+func (w *World) EntitiesByName(name string) []Entity {
+	ents := w.names.byName[name]
+	result := make([]Entity, len(ents))
+	copy(result, ents)
+	return result
+}
+
+// SetUniqueNames sets whether the names must be unique in the world. It
+// returns ErrNameTaken (and the mode is not changed) if more than one entity
+// already has the same name.
+func (w *World) SetUniqueNames(unique bool) error {
+	if unique {
+		for name, ents := range w.names.byName {
+			if len(ents) > 1 {
+				return fmt.Errorf("%w: %q", ErrNameTaken, name)
+			}
+		}
+	}
+	w.names.unique = unique
+	return nil
+}
+
+// UniqueNames returns true if the names must be unique in the world.
+func (w *World) UniqueNames() bool {
+	return w.names.unique
+}
+
+// EntityLabel returns a human-readable label of an entity for debugging:
+// "name#ID" if the entity has a name, or "#ID".
+func (w *World) EntityLabel(e Entity) string {
+	return w.names.names[e] + "#" + strconv.FormatUint(uint64(e), 10)
+}
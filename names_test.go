@@ -0,0 +1,103 @@
+package ecs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityNames(t *testing.T) {
+	w := NewEmptyWorld()
+	boss := w.NewEntity()
+	m1 := w.NewEntity()
+	m2 := w.NewEntity()
+	assert.NoError(t, w.SetName(boss, "boss"))
+	assert.NoError(t, w.SetName(m2, "minion"))
+	assert.NoError(t, w.SetName(m1, "minion"))
+
+	e, ok := w.EntityByName("boss")
+	assert.True(t, ok)
+	assert.Equal(t, boss, e)
+	assert.Equal(t, []Entity{m1, m2}, w.EntitiesByName("minion"))
+	assert.Equal(t, "minion", w.Name(m2))
+	assert.Equal(t, "boss#1", w.EntityLabel(boss))
+
+	assert.ErrorIs(t, w.SetUniqueNames(true), ErrNameTaken)
+	assert.False(t, w.UniqueNames())
+	assert.NoError(t, w.SetName(m2, "minion2"))
+	assert.NoError(t, w.SetUniqueNames(true))
+	assert.ErrorIs(t, w.SetName(m1, "boss"), ErrNameTaken)
+	assert.NoError(t, w.SetName(boss, "boss"))
+	assert.Equal(t, "minion", w.Name(m1))
+
+	assert.NoError(t, w.SetName(m1, ""))
+	assert.ErrorIs(t, w.SetName(Entity(42), "ghost"), ErrEntityNotFound)
+	_, ok = w.EntityByName("ghost")
+	assert.False(t, ok)
+	_, ok = w.EntityByName("minion")
+	assert.False(t, ok)
+	w.Remove(boss)
+	_, ok = w.EntityByName("boss")
+	assert.False(t, ok)
+	assert.Equal(t, "", w.Name(boss))
+}
+
+func TestEntityNamesSerialization(t *testing.T) {
+	w := NewEmptyWorld()
+	boss := w.NewEntity()
+	Set(w, boss, registeredComponent{Name: "x"})
+	assert.NoError(t, w.SetName(boss, "boss"))
+	spawn := w.NewEntity() // no components
+	assert.NoError(t, w.SetName(spawn, "spawn point"))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(buf))
+	assert.Contains(t, buf.String(), `name = "spawn point"`)
+
+	w2 := NewEmptyWorld()
+	assert.NoError(t, w2.UnmarshalFrom(bytes.NewReader(buf.Bytes())))
+	e, ok := w2.EntityByName("spawn point")
+	assert.True(t, ok)
+	assert.Equal(t, w.EntityUUID(spawn), w2.EntityUUID(e))
+	e, _ = w2.EntityByName("boss")
+	assert.True(t, Contains[registeredComponent](w2, e))
+
+	w3 := NewEmptyWorld()
+	assert.NoError(t, w3.SetUniqueNames(true))
+	assert.NoError(t, w3.SetName(w3.NewEntity(), "boss"))
+	err := w3.UnmarshalFromMode(bytes.NewReader(buf.Bytes()), DeserializeStrict)
+	assert.ErrorIs(t, err, ErrNameTaken)
+	assert.Equal(t, 1, len(w3.AllEntities()))
+}
+
+func TestEntityNamesLoadUnique(t *testing.T) {
+	w := NewEmptyWorld()
+	assert.NoError(t, w.SetUniqueNames(true))
+	a := w.NewEntity()
+	b := w.NewEntity()
+	c := w.NewEntity()
+	assert.NoError(t, w.SetName(a, "alpha"))
+	assert.NoError(t, w.SetName(b, "beta"))
+	assert.NoError(t, w.SetName(c, "gamma"))
+	for _, e := range []Entity{a, b, c} {
+		_ = w.EntityUUID(e)
+	}
+	saved := w.Snapshot()
+
+	// swap the names and remove the name of c
+	assert.NoError(t, w.SetName(a, ""))
+	assert.NoError(t, w.SetName(b, "alpha"))
+	assert.NoError(t, w.SetName(a, "beta"))
+	assert.NoError(t, w.SetName(c, ""))
+	buf := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(buf))
+
+	assert.NoError(t, w.Restore(saved))
+	assert.NoError(t, w.UnmarshalFromMode(buf, DeserializeStrict))
+	assert.Equal(t, "beta", w.Name(a))
+	assert.Equal(t, "alpha", w.Name(b))
+	assert.Equal(t, "", w.Name(c))
+	_, ok := w.EntityByName("gamma")
+	assert.False(t, ok)
+}
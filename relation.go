@@ -0,0 +1,316 @@
+package ecs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+)
+
+// RelationType is a data type that has a Pkg() function. It identifies a kind
+// of relation between two entities (e.g. ChildOf, OwnedBy, DockedAt). The
+// relation can also hold data.
+type RelationType interface {
+	Pkg() string
+}
+
+// IRelationStore is an interface for relation stores.
+type IRelationStore interface {
+	Len() int
+
+	removeEntity(e Entity)
+	entityRelations(e Entity, fn func(source, target Entity, d interface{}))
+	dataExtract(fn func(source, target Entity, d interface{}))
+	dataDecode(d toml.Primitive, md toml.MetaData) (interface{}, error)
+	dataDecodeRaw(raw interface{}) (interface{}, error)
+	dataRelate(source, target Entity, d interface{})
+	dataUnrelate(source, target Entity) bool
+	snapshot(dst interface{}) interface{}
+	restore(s interface{})
+}
+
+// RelationStore holds the relations of type R of a world, indexed in both
+// directions.
+type RelationStore[R RelationType] struct {
+	world   *World
+	targets map[Entity]map[Entity]R        // source -> target -> data
+	sources map[Entity]map[Entity]struct{} // target -> sources
+	count   int
+}
+
+// Relate adds or replaces the relation from source to target. It returns
+// ErrEntityNotFound if source or target is not in the world.
+func (s *RelationStore[R]) Relate(source, target Entity, data R) error {
+	for _, e := range []Entity{source, target} {
+		if !s.world.hasEntity(e) {
+			return fmt.Errorf("%w: %d", ErrEntityNotFound, e)
+		}
+	}
+	s.relate(source, target, data)
+	return nil
+}
+
+func (s *RelationStore[R]) relate(source, target Entity, data R) {
+	t := s.targets[source]
+	if t == nil {
+		t = make(map[Entity]R)
+		s.targets[source] = t
+	}
+	if _, ok := t[target]; !ok {
+		s.count++
+	}
+	t[target] = data
+	src := s.sources[target]
+	if src == nil {
+		src = make(map[Entity]struct{})
+		s.sources[target] = src
+	}
+	src[source] = struct{}{}
+}
+
+// Unrelate removes the relation from source to target. It returns false if
+// the relation was not found.
+func (s *RelationStore[R]) Unrelate(source, target Entity) bool {
+	t := s.targets[source]
+	if _, ok := t[target]; !ok {
+		return false
+	}
+	delete(t, target)
+	if len(t) == 0 {
+		delete(s.targets, source)
+	}
+	src := s.sources[target]
+	delete(src, source)
+	if len(src) == 0 {
+		delete(s.sources, target)
+	}
+	s.count--
+	return true
+}
+
+// Get returns the data of the relation from source to target.
+func (s *RelationStore[R]) Get(source, target Entity) (R, bool) {
+	d, ok := s.targets[source][target]
+	return d, ok
+}
+
+// Targets returns the targets (sorted) of the relations from source.
+func (s *RelationStore[R]) Targets(source Entity) []Entity {
+	result := make([]Entity, 0, len(s.targets[source]))
+	for e := range s.targets[source] {
+		result = append(result, e)
+	}
+	SortEntities(result)
+	return result
+}
+
+// Sources returns the sources (sorted) of the relations to target.
+func (s *RelationStore[R]) Sources(target Entity) []Entity {
+	result := make([]Entity, 0, len(s.sources[target]))
+	for e := range s.sources[target] {
+		result = append(result, e)
+	}
+	SortEntities(result)
+	return result
+}
+
+// Len returns the number of relations.
+func (s *RelationStore[R]) Len() int {
+	return s.count
+}
+
+// removeEntity removes all the relations from or to e
+func (s *RelationStore[R]) removeEntity(e Entity) {
+	for target := range s.targets[e] {
+		s.Unrelate(e, target)
+	}
+	for source := range s.sources[e] {
+		s.Unrelate(source, e)
+	}
+}
+
+// entityRelations calls fn for each relation from or to e
+func (s *RelationStore[R]) entityRelations(e Entity, fn func(source, target Entity, d interface{})) {
+	for target, d := range s.targets[e] {
+		fn(e, target, d)
+	}
+	for source := range s.sources[e] {
+		if source != e {
+			fn(source, e, s.targets[source][e])
+		}
+	}
+}
+
+// dataExtract calls fn for each relation, sorted by source and target.
+func (s *RelationStore[R]) dataExtract(fn func(source, target Entity, d interface{})) {
+	sources := make([]Entity, 0, len(s.targets))
+	for e := range s.targets {
+		sources = append(sources, e)
+	}
+	SortEntities(sources)
+	for _, source := range sources {
+		for _, target := range s.Targets(source) {
+			fn(source, target, s.targets[source][target])
+		}
+	}
+}
+
+func (s *RelationStore[R]) dataDecode(d toml.Primitive, md toml.MetaData) (interface{}, error) {
+	var x R
+	if err := md.PrimitiveDecode(d, &x); err != nil {
+		return nil, fmt.Errorf("failed to decode relation %T: %v", x, err)
+	}
+	return x, nil
+}
+
+// dataDecodeRaw decodes raw data (see encodeRaw). It must be called with the
+// decoder world set.
+func (s *RelationStore[R]) dataDecodeRaw(raw interface{}) (interface{}, error) {
+	var x R
+	if err := decodeRaw(raw, &x); err != nil {
+		return nil, fmt.Errorf("failed to decode relation %T: %v", x, err)
+	}
+	return x, nil
+}
+
+func (s *RelationStore[R]) dataUnrelate(source, target Entity) bool {
+	return s.Unrelate(source, target)
+}
+
+// dataRelate is the untyped version of Relate. It panics if d is not a R.
+func (s *RelationStore[R]) dataRelate(source, target Entity, d interface{}) {
+	s.relate(source, target, d.(R))
+}
+
+// SerializedRelation is a relation between two entities of a SerializedWorld.
+type SerializedRelation struct {
+	Relation string      `toml:"relation"`
+	Source   uuid.UUID   `toml:"source"`
+	Target   uuid.UUID   `toml:"target"`
+	Data     interface{} `toml:"data"`
+}
+
+// DeserializedRelation is a relation between two entities of a
+// DeserializedWorld.
+type DeserializedRelation struct {
+	Relation string         `toml:"relation"`
+	Source   uuid.UUID      `toml:"source"`
+	Target   uuid.UUID      `toml:"target"`
+	Data     toml.Primitive `toml:"data"`
+}
+
+var (
+	globalRelations = struct {
+		lock      sync.RWMutex
+		factories map[string]func(w *World) IRelationStore
+	}{
+		factories: make(map[string]func(w *World) IRelationStore),
+	}
+)
+
+// RegisterRelation registers the relation type R globally, so any world is
+// able to load the relations of type R from a save file.
+func RegisterRelation[R RelationType]() {
+	var zv R
+	globalRelations.lock.Lock()
+	defer globalRelations.lock.Unlock()
+	globalRelations.factories[zv.Pkg()] = func(w *World) IRelationStore {
+		return GetRelationStore[R](w)
+	}
+}
+
+// GetRelationStore returns the relation store of the relation type R.
+func GetRelationStore[R RelationType](w *World) *RelationStore[R] {
+	if w.relations == nil {
+		w.relations = make(map[string]IRelationStore)
+	}
+	var zv R
+	if s, ok := w.relations[zv.Pkg()]; ok {
+		return s.(*RelationStore[R])
+	}
+	s := &RelationStore[R]{
+		world:   w,
+		targets: make(map[Entity]map[Entity]R),
+		sources: make(map[Entity]map[Entity]struct{}),
+	}
+	w.relations[zv.Pkg()] = s
+	return s
+}
+
+// GetGenericRelation returns the relation store by its registry name (Pkg()).
+// It returns nil if the relation type is unknown.
+func (w *World) GetGenericRelation(registryName string) IRelationStore {
+	if s, ok := w.relations[registryName]; ok {
+		return s
+	}
+	globalRelations.lock.RLock()
+	factory := globalRelations.factories[registryName]
+	globalRelations.lock.RUnlock()
+	if factory == nil {
+		return nil
+	}
+	return factory(w)
+}
+
+// removeRelations removes all the relations from or to e
+func (w *World) removeRelations(e Entity) {
+	for _, s := range w.relations {
+		s.removeEntity(e)
+	}
+}
+
+// serializedRelations must be called with the encoder world set
+func (w *World) serializedRelations() []SerializedRelation {
+	names := make([]string, 0, len(w.relations))
+	for name := range w.relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]SerializedRelation, 0)
+	for _, name := range names {
+		w.relations[name].dataExtract(func(source, target Entity, d interface{}) {
+			result = append(result, SerializedRelation{
+				Relation: name,
+				Source:   w.EntityUUID(source),
+				Target:   w.EntityUUID(target),
+				Data:     d,
+			})
+		})
+	}
+	return result
+}
+
+// Relate adds or replaces the relation R from source to target. The relation
+// is removed when source or target is removed from the world. It returns
+// ErrEntityNotFound if source or target is not in the world.
+func Relate[R RelationType](w *World, source, target Entity, data R) error {
+	return GetRelationStore[R](w).Relate(source, target, data)
+}
+
+// Unrelate removes the relation R from source to target.
+func Unrelate[R RelationType](w *World, source, target Entity) bool {
+	return GetRelationStore[R](w).Unrelate(source, target)
+}
+
+// HasRelation returns true if source has the relation R to target.
+func HasRelation[R RelationType](w *World, source, target Entity) bool {
+	_, ok := GetRelationStore[R](w).Get(source, target)
+	return ok
+}
+
+// GetRelation returns the data of the relation R from source to target.
+func GetRelation[R RelationType](w *World, source, target Entity) (R, bool) {
+	return GetRelationStore[R](w).Get(source, target)
+}
+
+// Targets returns the entities that source has the relation R to.
+func Targets[R RelationType](w *World, source Entity) []Entity {
+	return GetRelationStore[R](w).Targets(source)
+}
+
+// Sources returns the entities that have the relation R to target.
+func Sources[R RelationType](w *World, target Entity) []Entity {
+	return GetRelationStore[R](w).Sources(target)
+}
@@ -0,0 +1,104 @@
+package ecs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ownedBy struct {
+	Since int
+}
+
+func (ownedBy) Pkg() string {
+	return "test.ownedBy"
+}
+
+type dockedAt struct{}
+
+func (dockedAt) Pkg() string {
+	return "test.dockedAt"
+}
+
+func init() {
+	RegisterRelation[ownedBy]()
+}
+
+func TestRelations(t *testing.T) {
+	w := NewEmptyWorld()
+	player := w.NewEntity()
+	ship := w.NewEntity()
+	sword := w.NewEntity()
+	station := w.NewEntity()
+
+	assert.NoError(t, Relate(w, ship, player, ownedBy{Since: 1}))
+	Relate(w, sword, player, ownedBy{Since: 2})
+	Relate(w, ship, station, dockedAt{})
+	Relate(w, ship, player, ownedBy{Since: 3})
+
+	assert.True(t, HasRelation[ownedBy](w, ship, player))
+	assert.False(t, HasRelation[ownedBy](w, player, ship))
+	d, ok := GetRelation[ownedBy](w, ship, player)
+	assert.True(t, ok)
+	assert.Equal(t, 3, d.Since)
+	assert.Equal(t, []Entity{ship, sword}, Sources[ownedBy](w, player))
+	assert.Equal(t, []Entity{player}, Targets[ownedBy](w, sword))
+	assert.Equal(t, 2, GetRelationStore[ownedBy](w).Len())
+
+	assert.True(t, Unrelate[ownedBy](w, sword, player))
+	assert.False(t, Unrelate[ownedBy](w, sword, player))
+	assert.Equal(t, []Entity{ship}, Sources[ownedBy](w, player))
+
+	// cleanup on both sides
+	Relate(w, player, station, dockedAt{})
+	assert.True(t, w.Remove(station))
+	assert.Empty(t, Targets[dockedAt](w, ship))
+	assert.Equal(t, 0, GetRelationStore[dockedAt](w).Len())
+	assert.True(t, w.Remove(player))
+	assert.Empty(t, Targets[ownedBy](w, ship))
+	assert.Equal(t, 0, GetRelationStore[ownedBy](w).Len())
+
+	// both ends must exist
+	assert.ErrorIs(t, Relate(w, ship, player, ownedBy{}), ErrEntityNotFound)
+	assert.ErrorIs(t, Relate(w, 0, ship, ownedBy{}), ErrEntityNotFound)
+	assert.Equal(t, 0, GetRelationStore[ownedBy](w).Len())
+}
+
+func TestRelationsSerialization(t *testing.T) {
+	w := NewEmptyWorld()
+	player := w.NewEntity()
+	ship := w.NewEntity()
+	Set(w, ship, registeredComponent{Name: "ship"})
+	Relate(w, ship, player, ownedBy{Since: 7})
+	Relate(w, ship, player, dockedAt{})
+	playerID := w.EntityUUID(player)
+	shipID := w.EntityUUID(ship)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(buf))
+	assert.Contains(t, buf.String(), "[[relations]]")
+
+	w2 := NewEmptyWorld()
+	err := w2.UnmarshalFromMode(bytes.NewReader(buf.Bytes()), DeserializeStrict)
+	assert.ErrorIs(t, err, ErrUnknownRelation)
+
+	err = w2.UnmarshalFromMode(bytes.NewReader(buf.Bytes()), DeserializeLenient)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, w2.LoadErrors(), ErrUnknownRelation)
+	ship2, _ := w2.EntityByUUID(shipID)
+	player2, ok := w2.EntityByUUID(playerID)
+	assert.True(t, ok)
+	d, ok := GetRelation[ownedBy](w2, ship2, player2)
+	assert.True(t, ok)
+	assert.Equal(t, 7, d.Since)
+	assert.False(t, HasRelation[dockedAt](w2, ship2, player2))
+
+	w3 := NewEmptyWorld()
+	GetRelationStore[dockedAt](w3)
+	assert.NoError(t, w3.UnmarshalFromMode(strings.NewReader(buf.String()), DeserializeStrict))
+	ship3, _ := w3.EntityByUUID(shipID)
+	player3, _ := w3.EntityByUUID(playerID)
+	assert.True(t, HasRelation[dockedAt](w3, ship3, player3))
+}
@@ -0,0 +1,399 @@
+package ecs
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// Relevance computes the entities that are visible to each observer entity
+// (interest management). Each observer has filters (see DistanceFilter,
+// TeamFilter and PredicateFilter); an entity is visible if it matches all
+// the filters of the observer.
+//
+// The visible sets are updated by Update, incrementally: only the entities
+// that were spawned or removed, or whose tracked components (the components
+// of the filters) changed with Set (Replace), Apply or Remove, are checked
+// again. The observers that changed are checked against the entities near
+// them if they have a DistanceFilter (see SpatialHash3D), or against all the
+// entities otherwise. Changes made through views or pointers are not tracked;
+// call Invalidate or InvalidateAll after them (and when the inputs of a
+// predicate change).
+type Relevance struct {
+	// EntityEntered is called by Update when an entity becomes visible to an
+	// observer.
+	EntityEntered func(observer, e uuid.UUID)
+	// EntityLeft is called by Update when an entity stops being visible to an
+	// observer (or is removed).
+	EntityLeft func(observer, e uuid.UUID)
+
+	world     *World
+	observers []*relevanceObserver
+	trackers  map[string]func() // component name -> untrack
+	hashes    map[string]relevanceHash
+	dirty     map[Entity]struct{}
+	all       bool
+}
+
+// RelevanceFilter decides if an entity is visible to an observer.
+type RelevanceFilter struct {
+	// bind tracks the components of the filter and returns its matcher
+	bind func(r *Relevance) relevanceMatcher
+}
+
+type relevanceMatcher struct {
+	match func(observer, e Entity) bool
+	// candidates (optional) returns the only entities that can match
+	candidates func(observer Entity) []Entity
+}
+
+// relevanceHash is the spatial index of a DistanceFilter
+type relevanceHash interface {
+	update(e Entity)
+	sync()
+	destroy()
+}
+
+type relevanceObserver struct {
+	entity     Entity
+	uuid       uuid.UUID
+	match      []func(observer, e Entity) bool
+	candidates func(observer Entity) []Entity
+	visible    map[Entity]uuid.UUID
+	full       bool // check all the entities (or candidates) on the next update
+}
+
+// relevanceTracker marks the entities whose component T changed as dirty
+type relevanceTracker[T ComponentType] struct {
+	r *Relevance
+}
+
+func (t *relevanceTracker[T]) indexSet(e Entity, d *T) {
+	t.r.dirty[e] = struct{}{}
+}
+
+func (t *relevanceTracker[T]) indexRemove(e Entity) {
+	t.r.dirty[e] = struct{}{}
+}
+
+func (t *relevanceTracker[T]) indexRebuild() {
+	t.r.all = true
+}
+
+// NewRelevance creates the relevance system of the world w.
+func NewRelevance(w *World) *Relevance {
+	r := &Relevance{
+		world:     w,
+		observers: make([]*relevanceObserver, 0),
+		trackers:  make(map[string]func()),
+		hashes:    make(map[string]relevanceHash),
+		dirty:     make(map[Entity]struct{}),
+	}
+	w.relevances = append(w.relevances, r)
+	return r
+}
+
+// DistanceFilter matches the entities within a radius of the observer. Both
+// need the component T; pos returns its position. The entities are indexed by
+// a spatial hash, shared by the filters of T with the same radius (so they
+// must use the same pos).
+func DistanceFilter[T ComponentType](pos func(d *T) [3]float64, radius float64) RelevanceFilter {
+	return RelevanceFilter{
+		bind: func(r *Relevance) relevanceMatcher {
+			store := trackComponent[T](r)
+			var zv T
+			key := fmt.Sprintf("%s/%g", zv.Pkg(), radius)
+			if _, ok := r.hashes[key]; !ok {
+				r.hashes[key] = newSpatialHash(r.world, 3, math.Max(radius, 1), pos)
+			}
+			hash := r.hashes[key].(*spatialHash[T])
+			return relevanceMatcher{
+				match: func(observer, e Entity) bool {
+					od := store.ptr(observer)
+					ed := store.ptr(e)
+					if od == nil || ed == nil {
+						return false
+					}
+					return hash.dist2(pos(od), pos(ed)) <= radius*radius
+				},
+				candidates: func(observer Entity) []Entity {
+					if od := store.ptr(observer); od != nil {
+						return hash.radius(pos(od), radius)
+					}
+					return nil
+				},
+			}
+		},
+	}
+}
+
+// TeamFilter matches the entities of the same team as the observer. Both
+// need the component T; team returns its team.
+func TeamFilter[T ComponentType, K comparable](team func(d *T) K) RelevanceFilter {
+	return RelevanceFilter{
+		bind: func(r *Relevance) relevanceMatcher {
+			store := trackComponent[T](r)
+			return relevanceMatcher{
+				match: func(observer, e Entity) bool {
+					od := store.ptr(observer)
+					ed := store.ptr(e)
+					return od != nil && ed != nil && team(od) == team(ed)
+				},
+			}
+		},
+	}
+}
+
+// PredicateFilter matches the entities for which fn returns true. The inputs
+// of fn are not tracked (see Relevance).
+func PredicateFilter(fn func(w *World, observer, e Entity) bool) RelevanceFilter {
+	return RelevanceFilter{
+		bind: func(r *Relevance) relevanceMatcher {
+			return relevanceMatcher{
+				match: func(observer, e Entity) bool {
+					return fn(r.world, observer, e)
+				},
+			}
+		},
+	}
+}
+
+// trackComponent marks the entities as dirty when the component T changes
+func trackComponent[T ComponentType](r *Relevance) *ComponentStore[T] {
+	var zv T
+	store := GetComponentStore[T](r.world)
+	if _, ok := r.trackers[zv.Pkg()]; !ok {
+		t := &relevanceTracker[T]{r}
+		store.indexes = append(store.indexes, t)
+		r.trackers[zv.Pkg()] = func() {
+			store.removeIndex(t)
+		}
+	}
+	return store
+}
+
+// AddObserver adds (or replaces) an observer with the filters. Its visible
+// set is computed on the next Update.
+func (r *Relevance) AddObserver(observer Entity, filters ...RelevanceFilter) {
+	o := &relevanceObserver{
+		entity:  observer,
+		uuid:    r.world.EntityUUID(observer),
+		match:   make([]func(observer, e Entity) bool, 0, len(filters)),
+		visible: make(map[Entity]uuid.UUID),
+		full:    true,
+	}
+	for _, f := range filters {
+		m := f.bind(r)
+		o.match = append(o.match, m.match)
+		if o.candidates == nil {
+			o.candidates = m.candidates
+		}
+	}
+	if i, ok := r.observerIndex(observer); ok {
+		o.visible = r.observers[i].visible
+		r.observers[i] = o
+		return
+	}
+	r.observers = append(r.observers, o)
+}
+
+// RemoveObserver removes an observer. EntityLeft is called for all the
+// entities it sees.
+func (r *Relevance) RemoveObserver(observer Entity) bool {
+	i, ok := r.observerIndex(observer)
+	if !ok {
+		return false
+	}
+	o := r.observers[i]
+	r.observers = append(r.observers[:i], r.observers[i+1:]...)
+	for _, e := range o.visibleEntities() {
+		r.leave(o, e)
+	}
+	return true
+}
+
+// Visible returns the entities (sorted) visible to the observer.
+func (r *Relevance) Visible(observer Entity) []Entity {
+	if i, ok := r.observerIndex(observer); ok {
+		return r.observers[i].visibleEntities()
+	}
+	return []Entity{}
+}
+
+// IsVisible returns true if the entity is visible to the observer.
+func (r *Relevance) IsVisible(observer, e Entity) bool {
+	if i, ok := r.observerIndex(observer); ok {
+		_, visible := r.observers[i].visible[e]
+		return visible
+	}
+	return false
+}
+
+// Invalidate checks the entity again on the next Update. If the entity is an
+// observer, the entities near it (or all the entities) are checked against it.
+func (r *Relevance) Invalidate(e Entity) {
+	r.dirty[e] = struct{}{}
+	for _, h := range r.hashes {
+		h.update(e)
+	}
+}
+
+// InvalidateAll checks all the entities again on the next Update.
+func (r *Relevance) InvalidateAll() {
+	r.all = true
+	for _, h := range r.hashes {
+		h.sync()
+	}
+}
+
+// Update updates the visible sets of the observers and calls EntityEntered
+// and EntityLeft. Observers that were removed from the world are removed.
+func (r *Relevance) Update() {
+	w := r.world
+	for _, o := range append([]*relevanceObserver(nil), r.observers...) {
+		if _, ok := getEntityIndex(w.entities, o.entity); !ok {
+			r.RemoveObserver(o.entity)
+		}
+	}
+	dirty := make([]Entity, 0, len(r.dirty))
+	for e := range r.dirty {
+		dirty = append(dirty, e)
+	}
+	SortEntities(dirty)
+	for _, o := range r.observers {
+		_, changed := r.dirty[o.entity]
+		if r.all || ((changed || o.full) && o.candidates == nil) {
+			r.updateAll(o)
+			continue
+		}
+		if changed || o.full {
+			r.updateNear(o)
+		}
+		for _, e := range dirty {
+			_, exists := getEntityIndex(w.entities, e)
+			r.update(o, e, exists)
+		}
+	}
+	for e := range r.dirty {
+		delete(r.dirty, e)
+	}
+	r.all = false
+}
+
+// Destroy stops tracking the entities and the components of the filters.
+func (r *Relevance) Destroy() {
+	for name, untrack := range r.trackers {
+		untrack()
+		delete(r.trackers, name)
+	}
+	for key, h := range r.hashes {
+		h.destroy()
+		delete(r.hashes, key)
+	}
+	w := r.world
+	for i, v := range w.relevances {
+		if v == r {
+			w.relevances = append(w.relevances[:i], w.relevances[i+1:]...)
+			break
+		}
+	}
+}
+
+// invalidateRelevance marks the entities as dirty in the relevances of the
+// world. It is called for the spawned and removed entities, which the
+// component trackers don't see.
+func (w *World) invalidateRelevance(ents ...Entity) {
+	for _, r := range w.relevances {
+		for _, e := range ents {
+			r.dirty[e] = struct{}{}
+		}
+	}
+}
+
+func (r *Relevance) observerIndex(observer Entity) (int, bool) {
+	for i, o := range r.observers {
+		if o.entity == observer {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// updateAll checks all the entities against the observer
+func (r *Relevance) updateAll(o *relevanceObserver) {
+	o.full = false
+	for _, e := range o.visibleEntities() {
+		if _, ok := getEntityIndex(r.world.entities, e); !ok {
+			r.leave(o, e)
+		}
+	}
+	for _, e := range r.world.entities {
+		r.update(o, e, true)
+	}
+}
+
+// updateNear checks the visible entities and the candidates of the filters
+// (the entities near the observer) against the observer
+func (r *Relevance) updateNear(o *relevanceObserver) {
+	o.full = false
+	for _, e := range o.visibleEntities() {
+		r.update(o, e, r.world.hasEntity(e))
+	}
+	for _, e := range o.candidates(o.entity) {
+		r.update(o, e, true)
+	}
+}
+
+func (r *Relevance) update(o *relevanceObserver, e Entity, exists bool) {
+	visible := exists
+	for i := 0; visible && i < len(o.match); i++ {
+		visible = o.match[i](o.entity, e)
+	}
+	_, was := o.visible[e]
+	if visible && !was {
+		id := r.world.EntityUUID(e)
+		o.visible[e] = id
+		if r.EntityEntered != nil {
+			r.EntityEntered(o.uuid, id)
+		}
+	} else if !visible && was {
+		r.leave(o, e)
+	}
+}
+
+func (r *Relevance) leave(o *relevanceObserver, e Entity) {
+	id := o.visible[e]
+	delete(o.visible, e)
+	if r.EntityLeft != nil {
+		r.EntityLeft(o.uuid, id)
+	}
+}
+
+func (o *relevanceObserver) visibleEntities() []Entity {
+	ents := make([]Entity, 0, len(o.visible))
+	for e := range o.visible {
+		ents = append(ents, e)
+	}
+	SortEntities(ents)
+	return ents
+}
+
+// filterState returns the state (see SerializedState) with only the entities
+// visible to the observer.
+func (r *Relevance) filterState(observer Entity, state SerializedWorld) SerializedWorld {
+	visible := make(map[uuid.UUID]bool)
+	if i, ok := r.observerIndex(observer); ok {
+		for _, id := range r.observers[i].visible {
+			visible[id] = true
+		}
+	}
+	filtered := state
+	filtered.Entities = make([]SerializedEntity, 0, len(visible))
+	for _, ent := range state.Entities {
+		if visible[ent.UUID] {
+			filtered.Entities = append(filtered.Entities, ent)
+		}
+	}
+	return filtered
+}
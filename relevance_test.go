@@ -0,0 +1,207 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type relevanceTeam struct {
+	Team string
+}
+
+func (relevanceTeam) Pkg() string {
+	return "test.relevanceTeam"
+}
+
+func replPos(d *replPosition) [3]float64 {
+	return [3]float64{d.X, d.Y, 0}
+}
+
+func TestRelevance(t *testing.T) {
+	w := NewEmptyWorld()
+	r := NewRelevance(w)
+	defer r.Destroy()
+	events := make([]string, 0)
+	r.EntityEntered = func(observer, e uuid.UUID) {
+		events = append(events, "+"+w.Name(w.entityUUIDs[e]))
+	}
+	r.EntityLeft = func(observer, e uuid.UUID) {
+		events = append(events, "-"+e.String())
+	}
+	spawn := func(name string, x float64, team string) Entity {
+		e := w.NewEntity()
+		_ = w.SetName(e, name)
+		Set(w, e, replPosition{X: x})
+		Set(w, e, relevanceTeam{Team: team})
+		return e
+	}
+	player := spawn("player", 0, "red")
+	ally := spawn("ally", 50, "red")
+	enemy := spawn("enemy", 5, "blue")
+	far := spawn("far", 100, "blue")
+
+	// visible: near or in the same team, except the hidden ones
+	hidden := map[Entity]bool{}
+	r.AddObserver(player,
+		PredicateFilter(func(w *World, observer, e Entity) bool {
+			return !hidden[e]
+		}),
+		DistanceFilter(replPos, 10),
+	)
+	team := spawn("team", 0, "red")
+	r.AddObserver(team, TeamFilter(func(d *relevanceTeam) string { return d.Team }))
+	r.Update()
+	assert.Equal(t, []Entity{player, enemy, team}, r.Visible(player))
+	assert.Equal(t, []Entity{player, ally, team}, r.Visible(team))
+	assert.Equal(t, []string{"+player", "+enemy", "+team", "+player", "+ally", "+team"}, events)
+
+	// incremental updates
+	events = events[:0]
+	farID := w.EntityUUID(far)
+	enemyID := w.EntityUUID(enemy)
+	Set(w, far, replPosition{X: 8})
+	Apply(w, enemy, func(d *replPosition) { d.X = 20 })
+	Set(w, ally, relevanceTeam{Team: "blue"})
+	r.Update()
+	assert.Equal(t, []Entity{player, far, team}, r.Visible(player))
+	assert.True(t, r.IsVisible(player, far))
+	assert.False(t, r.IsVisible(team, ally))
+	assert.Equal(t, []string{"-" + enemyID.String(), "+far", "-" + w.EntityUUID(ally).String()}, events)
+
+	// the observer moves
+	events = events[:0]
+	Set(w, player, replPosition{X: 25})
+	r.Update()
+	assert.Equal(t, []Entity{player, enemy}, r.Visible(player))
+
+	// predicates are not tracked
+	hidden[enemy] = true
+	r.Update()
+	assert.True(t, r.IsVisible(player, enemy))
+	r.Invalidate(enemy)
+	r.Update()
+	assert.False(t, r.IsVisible(player, enemy))
+
+	// removed entities leave with their UUID
+	Set(w, far, replPosition{X: 26})
+	r.Update()
+	assert.True(t, r.IsVisible(player, far))
+	events = events[:0]
+	w.Remove(far)
+	w.Remove(team)
+	r.Update()
+	assert.Equal(t, []Entity{}, r.Visible(team))
+	assert.Equal(t, []Entity{player}, r.Visible(player))
+	assert.Equal(t, 3, len(events)) // far from player; player and team from team
+	assert.Contains(t, events, "-"+farID.String())
+}
+
+func TestRelevanceReplication(t *testing.T) {
+	server := NewEmptyWorld()
+	player := server.NewEntity()
+	near := server.NewEntity()
+	far := server.NewEntity()
+	Set(server, player, replPosition{X: 0})
+	Set(server, near, replPosition{X: 5})
+	Set(server, far, replPosition{X: 50})
+
+	r := NewRelevance(server)
+	r.AddObserver(player, DistanceFilter(replPos, 10))
+	rs := NewReplicationServer(server)
+	st, ct := NewLoopbackTransport()
+	rs.Connect(st).SetRelevance(r, player)
+	client := NewEmptyWorld()
+	rc := NewReplicationClient(client, ct)
+
+	r.Update()
+	assert.NoError(t, rs.Update())
+	_, err := rc.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(client.entities))
+	_, ok := client.EntityByUUID(server.EntityUUID(far))
+	assert.False(t, ok)
+
+	// far enters and near leaves
+	Set(server, far, replPosition{X: 9})
+	Set(server, near, replPosition{X: 30})
+	r.Update()
+	assert.NoError(t, rs.Update())
+	_, err = rc.Poll()
+	assert.NoError(t, err)
+	_, ok = client.EntityByUUID(server.EntityUUID(far))
+	assert.True(t, ok)
+	_, ok = client.EntityByUUID(server.EntityUUID(near))
+	assert.False(t, ok)
+	assert.Equal(t, 2, len(client.entities))
+}
+
+func TestRelevancePredicateOnly(t *testing.T) {
+	w := NewEmptyWorld()
+	r := NewRelevance(w)
+	defer r.Destroy()
+	left := 0
+	r.EntityLeft = func(observer, e uuid.UUID) {
+		left++
+	}
+	obs := w.NewEntity()
+	e := w.NewEntity()
+	r.AddObserver(obs, PredicateFilter(func(w *World, observer, e Entity) bool {
+		return true
+	}))
+	r.Update()
+	assert.Equal(t, []Entity{obs, e}, r.Visible(obs))
+
+	// spawns and removals are tracked without components
+	e2 := w.NewEntity()
+	r.Update()
+	assert.Equal(t, []Entity{obs, e, e2}, r.Visible(obs))
+	w.Remove(e)
+	w.RemoveMany([]Entity{e2})
+	r.Update()
+	assert.Equal(t, []Entity{obs}, r.Visible(obs))
+	assert.Equal(t, 2, left)
+}
+
+func TestRelevanceMovingObserver(t *testing.T) {
+	w := NewEmptyWorld()
+	r := NewRelevance(w)
+	defer r.Destroy()
+	for i := 0; i < 1000; i++ {
+		Set(w, w.NewEntity(), replPosition{X: float64(i % 100 * 10), Y: float64(i / 100 * 10)})
+	}
+	player := w.NewEntity()
+	Set(w, player, replPosition{})
+	checks := 0
+	r.AddObserver(player,
+		PredicateFilter(func(w *World, observer, e Entity) bool {
+			checks++
+			return true
+		}),
+		DistanceFilter(replPos, 15),
+	)
+	expected := func() []Entity {
+		p, _ := GetComponentStore[replPosition](w).getCopy(player)
+		result := make([]Entity, 0)
+		for _, cd := range GetComponentStore[replPosition](w).all() {
+			d := cd.Data
+			if (d.X-p.X)*(d.X-p.X)+(d.Y-p.Y)*(d.Y-p.Y) <= 15*15 {
+				result = append(result, cd.Entity)
+			}
+		}
+		SortEntities(result)
+		return result
+	}
+	r.Update()
+	assert.Equal(t, expected(), r.Visible(player))
+
+	// only the entities near the old and new positions are checked
+	for x := 10.0; x <= 100; x += 10 {
+		checks = 0
+		Set(w, player, replPosition{X: x, Y: 50})
+		r.Update()
+		assert.Equal(t, expected(), r.Visible(player))
+		assert.Less(t, checks, 100)
+	}
+}
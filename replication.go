@@ -0,0 +1,293 @@
+package ecs
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+)
+
+var (
+	replicatedComponents = struct {
+		lock  sync.RWMutex
+		names map[string]bool
+	}{
+		names: make(map[string]bool),
+	}
+)
+
+// SetComponentReplicated sets whether the component type T is replicated by
+// a ReplicationServer. Replicated components are also registered (see
+// RegisterComponent), so the clients can decode them.
+func SetComponentReplicated[T ComponentType](replicated bool) {
+	var zv T
+	if replicated {
+		RegisterComponent[T]()
+	}
+	replicatedComponents.lock.Lock()
+	defer replicatedComponents.lock.Unlock()
+	if replicated {
+		replicatedComponents.names[zv.Pkg()] = true
+	} else {
+		delete(replicatedComponents.names, zv.Pkg())
+	}
+}
+
+func isComponentReplicated(name string) bool {
+	replicatedComponents.lock.RLock()
+	defer replicatedComponents.lock.RUnlock()
+	return replicatedComponents.names[name]
+}
+
+// replicationMessage is a message from the server to a client
+type replicationMessage struct {
+	Tick  uint64     `toml:"tick"`
+	Delta WorldDelta `toml:"delta"`
+}
+
+// ReplicationServer replicates the entities of a world, and their components
+// marked with SetComponentReplicated, to the connected clients. Each Update
+// sends to each client the changes (spawns, despawns and changed fields) since
+// the last state sent to it. Entities are identified by their UUID, so entity
+// references map across worlds. Names and relations are not replicated.
+type ReplicationServer struct {
+	world *World
+	conns []*ReplicationConn
+}
+
+// ReplicationConn is a client connection of a ReplicationServer.
+type ReplicationConn struct {
+	transport Transport
+	prev      SerializedWorld // the last state sent
+	relevance *Relevance
+	observer  Entity
+}
+
+// NewReplicationServer creates a replication server of the world w.
+func NewReplicationServer(w *World) *ReplicationServer {
+	return &ReplicationServer{
+		world: w,
+		conns: make([]*ReplicationConn, 0),
+	}
+}
+
+// Connect adds a client connection. The full state is sent to it on the next
+// Update.
+func (s *ReplicationServer) Connect(t Transport) *ReplicationConn {
+	c := &ReplicationConn{
+		transport: t,
+	}
+	s.conns = append(s.conns, c)
+	return c
+}
+
+// Disconnect removes a client connection. The transport is not closed.
+func (s *ReplicationServer) Disconnect(c *ReplicationConn) bool {
+	for i, v := range s.conns {
+		if v == c {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of connections.
+func (s *ReplicationServer) Len() int {
+	return len(s.conns)
+}
+
+// Update sends the changes of the world to the clients (usually once per
+// tick). Empty deltas are not sent. A connection that fails to send is
+// disconnected, and the first error is returned after all the connections are
+// updated.
+//
+// The changes are not tracked: each Update encodes the replicated components
+// of all the entities and compares them with the last state of each
+// connection, so its cost is O(world) per call (plus O(visible) per
+// connection).
+func (s *ReplicationServer) Update() error {
+	state, refs, err := s.world.replicatedState()
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, c := range append([]*ReplicationConn(nil), s.conns...) {
+		if err := c.send(s.world.tick, state, refs); err != nil {
+			s.Disconnect(c)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// SetRelevance limits the entities replicated to the connection to the ones
+// visible to the observer (see Relevance). Entities that stop being visible
+// are despawned on the client. Entities referenced by the visible ones are
+// spawned on the client (without components) until they stop being
+// referenced, so the references stay valid. Relevance.Update must be called
+// before ReplicationServer.Update.
+func (c *ReplicationConn) SetRelevance(r *Relevance, observer Entity) {
+	c.relevance = r
+	c.observer = observer
+}
+
+func (c *ReplicationConn) send(tick uint64, state SerializedWorld, refs map[uuid.UUID][]uuid.UUID) error {
+	if c.relevance != nil {
+		state = withReferenced(c.relevance.filterState(c.observer, state), refs)
+	}
+	d := Diff(c.prev, state)
+	if d.IsEmpty() {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(replicationMessage{Tick: tick, Delta: *d}); err != nil {
+		return fmt.Errorf("failed to encode replication message: %w", err)
+	}
+	if err := c.transport.Send(buf.Bytes()); err != nil {
+		return err
+	}
+	c.prev = state
+	return nil
+}
+
+// replicatedState returns the state of the world (see SerializedState) with
+// all the entities and only the replicated components, and the entities
+// referenced by the components of each entity.
+func (w *World) replicatedState() (SerializedWorld, map[uuid.UUID][]uuid.UUID, error) {
+	encoderMutex.Lock()
+	defer encoderMutex.Unlock()
+	setEncoderWorld(w)
+	defer setEncoderWorld(nil)
+	var found []uuid.UUID
+	encoderRefs = func(id uuid.UUID) {
+		found = append(found, id)
+	}
+	defer func() {
+		encoderRefs = nil
+	}()
+
+	sw := SerializedWorld{
+		Entities: make([]SerializedEntity, len(w.entities)),
+		Enabled:  w.enabled,
+	}
+	rows := make(map[Entity]int, len(w.entities))
+	for i, e := range w.entities {
+		rows[e] = i
+		sw.Entities[i] = SerializedEntity{
+			UUID:       w.EntityUUID(e),
+			Components: make([]interface{}, 0),
+		}
+	}
+	names := make([]string, 0)
+	for name := range w.components {
+		if isComponentReplicated(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	compIndex := make(map[string]int, len(names))
+	refs := make(map[uuid.UUID][]uuid.UUID)
+	var err error
+	for i, name := range names {
+		ci := i + 1
+		compIndex[name] = ci
+		w.components[name].dataExtract(func(e Entity, d interface{}) {
+			row, ok := rows[e]
+			if !ok || err != nil {
+				return
+			}
+			ent := &sw.Entities[row]
+			found = found[:0]
+			raw, rerr := encodeRaw(d)
+			if rerr != nil {
+				err = fmt.Errorf("failed to encode component %T of entity %s: %w", d, ent.UUID, rerr)
+				return
+			}
+			ent.Components = append(ent.Components, SerializedComponentData{
+				CI:   ci,
+				Data: raw,
+			})
+			refs[ent.UUID] = append(refs[ent.UUID], found...)
+		})
+		if err != nil {
+			return sw, nil, err
+		}
+	}
+	sw.ComponentIndex = componentIndexFromMap(compIndex)
+	return sw, refs, nil
+}
+
+// withReferenced adds to a filtered state (see Relevance.filterState) the
+// entities referenced by its entities, without components.
+func withReferenced(state SerializedWorld, refs map[uuid.UUID][]uuid.UUID) SerializedWorld {
+	present := make(map[uuid.UUID]bool, len(state.Entities))
+	for _, ent := range state.Entities {
+		present[ent.UUID] = true
+	}
+	n := len(state.Entities)
+	for i := 0; i < n; i++ {
+		for _, id := range refs[state.Entities[i].UUID] {
+			if present[id] {
+				continue
+			}
+			present[id] = true
+			state.Entities = append(state.Entities, SerializedEntity{
+				UUID:       id,
+				Components: make([]interface{}, 0),
+			})
+		}
+	}
+	return state
+}
+
+// ReplicationClient applies the changes sent by a ReplicationServer to a
+// world.
+type ReplicationClient struct {
+	world     *World
+	transport Transport
+	tick      uint64
+}
+
+// NewReplicationClient creates a replication client that updates the world
+// w with the messages received by t.
+func NewReplicationClient(w *World, t Transport) *ReplicationClient {
+	return &ReplicationClient{
+		world:     w,
+		transport: t,
+	}
+}
+
+// Poll applies all the received changes. It returns the number of deltas
+// applied.
+func (c *ReplicationClient) Poll() (int, error) {
+	n := 0
+	for {
+		msg, err := c.transport.Receive()
+		if err != nil {
+			return n, err
+		}
+		if msg == nil {
+			return n, nil
+		}
+		m := replicationMessage{}
+		if _, err := toml.NewDecoder(bytes.NewReader(msg)).Decode(&m); err != nil {
+			return n, fmt.Errorf("failed to decode replication message: %w", err)
+		}
+		if err := c.world.ApplyDelta(&m.Delta); err != nil {
+			return n, err
+		}
+		c.tick = m.Tick
+		n++
+	}
+}
+
+// Tick returns the server tick of the last delta applied.
+func (c *ReplicationClient) Tick() uint64 {
+	return c.tick
+}
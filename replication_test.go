@@ -0,0 +1,162 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type replPosition struct {
+	X float64
+	Y float64
+}
+
+func (replPosition) Pkg() string {
+	return "test.replPosition"
+}
+
+type replTarget struct {
+	Target Entity
+	Label  string
+}
+
+func (replTarget) Pkg() string {
+	return "test.replTarget"
+}
+
+func init() {
+	SetComponentReplicated[replPosition](true)
+	SetComponentReplicated[replTarget](true)
+}
+
+func TestReplication(t *testing.T) {
+	server := NewEmptyWorld()
+	rs := NewReplicationServer(server)
+	st, ct := NewLoopbackTransport()
+	rs.Connect(st)
+	client := NewEmptyWorld()
+	rc := NewReplicationClient(client, ct)
+
+	ship := server.NewEntity()
+	station := server.NewEntity()
+	local := server.NewEntity()
+	Set(server, ship, replPosition{X: 1, Y: 2})
+	Set(server, ship, replTarget{Target: station, Label: "dock"})
+	Set(server, ship, BenchPos3{X: 1}) // not replicated
+	Set(server, station, replPosition{X: 10})
+	Set(server, local, BenchPos3{X: 2})
+
+	clientEntity := func(e Entity) Entity {
+		ce, ok := client.EntityByUUID(server.EntityUUID(e))
+		assert.True(t, ok)
+		return ce
+	}
+	get := func(e Entity) (p replPosition, tg replTarget) {
+		Apply(client, e, func(d *replPosition) { p = *d })
+		Apply(client, e, func(d *replTarget) { tg = *d })
+		return
+	}
+
+	server.tick = 1
+	assert.NoError(t, rs.Update())
+	n, err := rc.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, uint64(1), rc.Tick())
+	assert.Equal(t, 3, len(client.entities))
+	cship := clientEntity(ship)
+	cstation := clientEntity(station)
+	clocal := clientEntity(local) // without components
+	p, tg := get(cship)
+	assert.Equal(t, replPosition{X: 1, Y: 2}, p)
+	assert.Equal(t, replTarget{Target: cstation, Label: "dock"}, tg)
+	assert.False(t, Contains[BenchPos3](client, cship))
+
+	// nothing changed: nothing is sent
+	assert.NoError(t, rs.Update())
+	n, _ = rc.Poll()
+	assert.Equal(t, 0, n)
+
+	// field changes, removals and despawns
+	server.tick = 2
+	Apply(server, ship, func(d *replPosition) { d.X = 5 })
+	RemoveComponent[replTarget](server, ship)
+	server.Remove(station)
+	assert.NoError(t, rs.Update())
+	n, err = rc.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	p, _ = get(cship)
+	assert.Equal(t, replPosition{X: 5, Y: 2}, p)
+	assert.False(t, Contains[replTarget](client, cship))
+	assert.Equal(t, []Entity{cship, clocal}, client.entities)
+
+	// a new client gets the full state
+	st2, ct2 := NewLoopbackTransport()
+	conn := rs.Connect(st2)
+	client2 := NewEmptyWorld()
+	assert.NoError(t, rs.Update())
+	_, err = NewReplicationClient(client2, ct2).Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(client2.entities))
+
+	// a closed transport is disconnected
+	assert.NoError(t, ct2.Close())
+	Set(server, ship, replPosition{})
+	assert.ErrorIs(t, rs.Update(), ErrTransportClosed)
+	assert.Equal(t, 1, rs.Len())
+	assert.False(t, rs.Disconnect(conn))
+	n, err = rc.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestReplicationReferences(t *testing.T) {
+	server := NewEmptyWorld()
+	player := server.NewEntity()
+	ship := server.NewEntity()
+	local := server.NewEntity()
+	Set(server, player, replPosition{})
+	Set(server, ship, replPosition{X: 1})
+	Set(server, ship, replTarget{Target: local})
+	Set(server, local, BenchPos3{X: 2}) // not replicated, never visible
+	r := NewRelevance(server)
+	r.AddObserver(player, DistanceFilter(replPos, 10))
+	rs := NewReplicationServer(server)
+	st, ct := NewLoopbackTransport()
+	rs.Connect(st).SetRelevance(r, player)
+	client := NewEmptyWorld()
+	rc := NewReplicationClient(client, ct)
+	update := func() {
+		r.Update()
+		assert.NoError(t, rs.Update())
+		_, err := rc.Poll()
+		assert.NoError(t, err)
+	}
+
+	// the referenced entity is spawned without components
+	update()
+	assert.Equal(t, 3, len(client.entities))
+	clocal, ok := client.EntityByUUID(server.EntityUUID(local))
+	assert.True(t, ok)
+	cship, _ := client.EntityByUUID(server.EntityUUID(ship))
+	assert.True(t, Apply(client, cship, func(d *replTarget) {
+		assert.Equal(t, clocal, d.Target)
+	}))
+	assert.Equal(t, 0, len(client.ComponentsOf(clocal)))
+
+	// and despawned when it is not referenced anymore
+	Set(server, ship, replTarget{})
+	update()
+	_, ok = client.EntityByUUID(server.EntityUUID(local))
+	assert.False(t, ok)
+	assert.Equal(t, 2, len(client.entities))
+
+	// or removed
+	Set(server, ship, replTarget{Target: local})
+	update()
+	assert.Equal(t, 3, len(client.entities))
+	server.Remove(local)
+	update()
+	assert.Equal(t, 2, len(client.entities))
+}
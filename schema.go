@@ -0,0 +1,101 @@
+package ecs
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ComponentMigration transforms the raw (decoded) data of a component from a
+// schema version to the next one (version+1).
+type ComponentMigration func(data map[string]interface{}) (map[string]interface{}, error)
+
+type componentSchema struct {
+	version    int
+	migrations map[int]ComponentMigration
+}
+
+var (
+	componentSchemas = struct {
+		lock    sync.RWMutex
+		schemas map[string]*componentSchema
+	}{
+		schemas: make(map[string]*componentSchema),
+	}
+)
+
+// SetComponentVersion sets the current schema version of the component type T.
+// The version is recorded in the component_index of serialized worlds.
+// Components without a version are at version 0.
+func SetComponentVersion[T ComponentType](version int) {
+	var zv T
+	componentSchemas.lock.Lock()
+	defer componentSchemas.lock.Unlock()
+	getComponentSchema(zv.Pkg()).version = version
+}
+
+// RegisterComponentMigration registers a function that upgrades the raw data
+// of the component type T from the schema version "from" to "from+1". When
+// loading older data, the migrations are chained until the current version
+// is reached.
+func RegisterComponentMigration[T ComponentType](from int, fn ComponentMigration) {
+	var zv T
+	componentSchemas.lock.Lock()
+	defer componentSchemas.lock.Unlock()
+	getComponentSchema(zv.Pkg()).migrations[from] = fn
+}
+
+// ComponentVersion returns the current schema version of a component by its
+// registry name.
+func ComponentVersion(name string) int {
+	componentSchemas.lock.RLock()
+	defer componentSchemas.lock.RUnlock()
+	if s, ok := componentSchemas.schemas[name]; ok {
+		return s.version
+	}
+	return 0
+}
+
+// getComponentSchema must be called with the componentSchemas lock held
+func getComponentSchema(name string) *componentSchema {
+	s, ok := componentSchemas.schemas[name]
+	if !ok {
+		s = &componentSchema{
+			migrations: make(map[int]ComponentMigration),
+		}
+		componentSchemas.schemas[name] = s
+	}
+	return s
+}
+
+// migrateComponentData upgrades raw component data from the version "from"
+// to the current version of the component.
+func migrateComponentData(name string, data interface{}, from int) (interface{}, error) {
+	componentSchemas.lock.RLock()
+	defer componentSchemas.lock.RUnlock()
+	current := 0
+	s := componentSchemas.schemas[name]
+	if s != nil {
+		current = s.version
+	}
+	if from == current {
+		return data, nil
+	}
+	if from > current {
+		return nil, fmt.Errorf("component %s version %d is newer than the current version %d", name, from, current)
+	}
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("component %s data is not a table", name)
+	}
+	for v := from; v < current; v++ {
+		fn := s.migrations[v]
+		if fn == nil {
+			return nil, fmt.Errorf("no migration path for component %s from version %d to %d", name, v, current)
+		}
+		var err error
+		if m, err = fn(m); err != nil {
+			return nil, fmt.Errorf("failed to migrate component %s from version %d: %w", name, v, err)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,96 @@
+package ecs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaPosition struct {
+	X, Y float64
+}
+
+func (schemaPosition) Pkg() string {
+	return "test.schemaPosition"
+}
+
+type schemaTag struct {
+	Value string
+}
+
+func (schemaTag) Pkg() string {
+	return "test.schemaTag"
+}
+
+const schemaOldData = `enabled = true
+
+[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+
+  [[entities.components]]
+    ci = 1
+    [entities.components.data]
+      PosX = 1.5
+      PosY = 2.5
+
+[[component_index]]
+  Name = "test.schemaPosition"
+  Index = 1
+`
+
+func TestComponentMigration(t *testing.T) {
+	SetComponentVersion[schemaPosition](2)
+	RegisterComponentMigration[schemaPosition](0, func(data map[string]interface{}) (map[string]interface{}, error) {
+		data["PosX"], data["PosY"] = data["PosY"], data["PosX"]
+		return data, nil
+	})
+	RegisterComponentMigration[schemaPosition](1, func(data map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"X": data["PosX"],
+			"Y": data["PosY"],
+		}, nil
+	})
+	w := NewWorld()
+	_ = GetComponentStore[schemaPosition](w)
+	assert.NoError(t, w.UnmarshalFrom(bytes.NewBufferString(schemaOldData)))
+	var pos schemaPosition
+	assert.True(t, Apply(w, w.entities[0], func(p *schemaPosition) {
+		pos = *p
+	}))
+	assert.Equal(t, schemaPosition{X: 2.5, Y: 1.5}, pos)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, w.MarshalTo(buf))
+	assert.Contains(t, buf.String(), "Version = 2")
+
+	w2 := NewWorld()
+	_ = GetComponentStore[schemaPosition](w2)
+	assert.NoError(t, w2.UnmarshalFrom(buf))
+	assert.True(t, Apply(w2, w2.entities[0], func(p *schemaPosition) {
+		pos = *p
+	}))
+	assert.Equal(t, schemaPosition{X: 2.5, Y: 1.5}, pos)
+}
+
+func TestComponentMigrationMissing(t *testing.T) {
+	SetComponentVersion[schemaTag](1)
+	w := NewWorld()
+	_ = GetComponentStore[schemaTag](w)
+	data := bytes.NewBufferString(`[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+
+  [[entities.components]]
+    ci = 1
+    [entities.components.data]
+      Value = "a"
+
+[[component_index]]
+  Name = "test.schemaTag"
+  Index = 1
+`)
+	assert.NoError(t, w.UnmarshalFrom(data))
+	err := w.LoadErrors()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no migration path")
+}
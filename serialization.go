@@ -1,6 +1,10 @@
 package ecs
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/BurntSushi/toml"
@@ -15,25 +19,93 @@ var (
 	SerializerLogger Printer
 )
 
-type SerializedWorld struct {
-	Entities       []SerializedEntity `toml:"entities"`
-	ComponentIndex ComponentIndex     `toml:"component_index"`
-	Enabled        bool               `toml:"enabled"`
+var (
+	// ErrUnknownComponent is reported when the serialized data contains a
+	// component that is not registered in the world.
+	ErrUnknownComponent = errors.New("component not registered")
+	// ErrDuplicateUUID is reported when more than one serialized entity has
+	// the same UUID.
+	ErrDuplicateUUID = errors.New("duplicate entity UUID")
+	// ErrUnknownRelation is reported when the serialized data contains a
+	// relation type that is not registered in the world.
+	ErrUnknownRelation = errors.New("relation not registered")
+)
+
+// DeserializeMode defines how invalid data is handled while deserializing a
+// world.
+type DeserializeMode int
+
+const (
+	// DeserializeLenient loads all the valid data. The problems found are
+	// logged (see SerializerLogger) and available with World.LoadErrors.
+	DeserializeLenient DeserializeMode = iota
+	// DeserializeStrict aborts if any problem is found. The world is left
+	// untouched and the problems are returned as DeserializeErrors.
+	DeserializeStrict
+)
+
+// DeserializeError is a problem found while deserializing an entity.
+type DeserializeError struct {
+	EntityUUID uuid.UUID
+	Component  string // empty if the error is not related to a component
+	Err        error
 }
 
-type DeserializedWorld struct {
-	Entities       []DeserializedEntity `toml:"entities"`
+func (e *DeserializeError) Error() string {
+	if e.Component == "" {
+		return fmt.Sprintf("entity %s: %v", e.EntityUUID, e.Err)
+	}
+	return fmt.Sprintf("entity %s: component %s: %v", e.EntityUUID, e.Component, e.Err)
+}
+
+func (e *DeserializeError) Unwrap() error {
+	return e.Err
+}
+
+// DeserializeErrors holds all the problems found while deserializing a world.
+type DeserializeErrors []*DeserializeError
+
+func (e DeserializeErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, v := range e {
+		msgs = append(msgs, v.Error())
+	}
+	return fmt.Sprintf("%d deserialization error(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the errors matches the target.
+func (e DeserializeErrors) Is(target error) bool {
+	for _, v := range e {
+		if errors.Is(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type SerializedWorld struct {
+	Entities       []SerializedEntity   `toml:"entities"`
 	ComponentIndex ComponentIndex       `toml:"component_index"`
+	Relations      []SerializedRelation `toml:"relations,omitempty"`
 	Enabled        bool                 `toml:"enabled"`
 }
 
+type DeserializedWorld struct {
+	Entities       []DeserializedEntity   `toml:"entities"`
+	ComponentIndex ComponentIndex         `toml:"component_index"`
+	Relations      []DeserializedRelation `toml:"relations"`
+	Enabled        bool                   `toml:"enabled"`
+}
+
 type DeserializedEntity struct {
 	UUID       uuid.UUID                   `toml:"uuid"`
+	Name       string                      `toml:"name"`
 	Components []DeserializedComponentData `toml:"components"`
 }
 
 type SerializedEntity struct {
 	UUID       uuid.UUID     `toml:"uuid"`
+	Name       string        `toml:"name,omitempty"`
 	Components []interface{} `toml:"components"`
 }
 
@@ -51,9 +123,18 @@ type Encoder interface {
 	Encode(interface{}) error
 }
 
+// encoderMutex must be locked before decoderMutex when both are needed.
 var encoderMutex sync.Mutex
 var encoderWorld *World
 
+// encoderReadOnly prevents the encoder from assigning UUIDs to the entities
+// that don't have one (they are encoded as the nil UUID).
+var encoderReadOnly bool
+
+// encoderRefs (if set) is called with the UUID of each entity reference
+// encoded.
+var encoderRefs func(id uuid.UUID)
+
 var decoderMutex sync.Mutex
 var decoderWorld *World
 
@@ -61,6 +142,86 @@ func setEncoderWorld(w *World) {
 	encoderWorld = w
 }
 
+// encoderEntityUUID returns the UUID of e in the encoder world (see
+// encoderReadOnly).
+func encoderEntityUUID(e Entity) uuid.UUID {
+	var id uuid.UUID
+	if encoderReadOnly {
+		id = encoderWorld.entityIDs[e]
+	} else {
+		id = encoderWorld.EntityUUID(e)
+	}
+	if encoderRefs != nil && id != uuid.Nil {
+		encoderRefs(id)
+	}
+	return id
+}
+
 func setDecoderWorld(w *World) {
 	decoderWorld = w
 }
+
+// lockCodec locks the encoder and the decoder with w as the world of both.
+// It is used by the loads that change w, since the watchers and listeners
+// that run meanwhile may encode data with w (see World.encodeRaw). The
+// returned func unlocks them.
+func (w *World) lockCodec() func() {
+	encoderMutex.Lock()
+	decoderMutex.Lock()
+	setEncoderWorld(w)
+	setDecoderWorld(w)
+	w.codecLocked = true
+	return func() {
+		w.codecLocked = false
+		setDecoderWorld(nil)
+		setEncoderWorld(nil)
+		decoderMutex.Unlock()
+		encoderMutex.Unlock()
+	}
+}
+
+type rawEnvelope[T any] struct {
+	V T `toml:"v"`
+}
+
+// encodeRaw converts v to its raw (format agnostic) representation, using the
+// same path as the component serializer. Tables become map[string]interface{}.
+func encodeRaw(v interface{}) (interface{}, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(rawEnvelope[interface{}]{V: v}); err != nil {
+		return nil, err
+	}
+	x := rawEnvelope[interface{}]{}
+	if _, err := toml.Decode(buf.String(), &x); err != nil {
+		return nil, err
+	}
+	return x.V, nil
+}
+
+// encodeRaw converts v to its raw representation (see encodeRaw) with w as
+// the encoder world.
+func (w *World) encodeRaw(v interface{}) (interface{}, error) {
+	if w.codecLocked {
+		// e.g. an event recorded by a listener while w is loading
+		return encodeRaw(v)
+	}
+	encoderMutex.Lock()
+	defer encoderMutex.Unlock()
+	setEncoderWorld(w)
+	defer setEncoderWorld(nil)
+	return encodeRaw(v)
+}
+
+// decodeRaw decodes raw data (see encodeRaw) into v.
+func decodeRaw[T any](raw interface{}, v *T) error {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(rawEnvelope[interface{}]{V: raw}); err != nil {
+		return err
+	}
+	x := rawEnvelope[T]{V: *v}
+	if _, err := toml.Decode(buf.String(), &x); err != nil {
+		return err
+	}
+	*v = x.V
+	return nil
+}
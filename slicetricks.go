@@ -103,3 +103,55 @@ func SortEntities(s []Entity) {
 		return s[i] < s[j]
 	})
 }
+
+// mergeEntities merges two sorted entity slices. Entities found in both slices
+// are added only once. It also returns the entities of b that were not in a.
+func mergeEntities(a, b []Entity) ([]Entity, []Entity) {
+	if len(b) == 0 {
+		return a, b
+	}
+	if len(a) == 0 || a[len(a)-1] < b[0] {
+		return append(a, b...), b
+	}
+	result := make([]Entity, 0, len(a)+len(b))
+	added := make([]Entity, 0, len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			added = append(added, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	added = append(added, b[j:]...)
+	return append(result, b[j:]...), added
+}
+
+// subtractEntities removes the entities of b from a (both sorted) in place. It
+// also returns the entities of b that were in a.
+func subtractEntities(a, b []Entity) ([]Entity, []Entity) {
+	removed := make([]Entity, 0, len(b))
+	n := 0
+	j := 0
+	for _, e := range a {
+		for j < len(b) && b[j] < e {
+			j++
+		}
+		if j < len(b) && b[j] == e {
+			removed = append(removed, e)
+			continue
+		}
+		a[n] = e
+		n++
+	}
+	return a[:n], removed
+}
@@ -0,0 +1,382 @@
+package ecs
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var errSnapshotWorld = errors.New("the snapshot was taken from another world")
+
+// Snapshot is an in-memory copy of the state of a world (see World.Snapshot).
+// It holds the entities, the component data of all the stores, the
+// relations, the names, the UUIDs, the entity lists of the views and the
+// resources registered with SnapshotResource. The component data is copied by
+// value (shallow copies).
+type Snapshot struct {
+	world       *World
+	tick        uint64
+	lastEntity  Entity
+	entities    []Entity
+	entityIDs   map[Entity]uuid.UUID
+	entityUUIDs map[uuid.UUID]Entity
+	names       entityNames
+	components  map[string]interface{}
+	relations   map[string]interface{}
+	archetypes  *archetypeSnapshot
+	views       map[*viewCommon][]Entity
+	resources   map[string]interface{}
+}
+
+// Tick returns the tick of the world when the snapshot was taken.
+func (s *Snapshot) Tick() uint64 {
+	return s.tick
+}
+
+// Snapshot copies the state of the world. It can be restored with Restore.
+// Event queues and listeners, systems and the world data (except the
+// resources registered with SnapshotResource) are not part of the snapshot.
+func (w *World) Snapshot() *Snapshot {
+	s := &Snapshot{}
+	w.SnapshotInto(s)
+	return s
+}
+
+// SnapshotInto is like Snapshot, but it reuses the memory of a previous
+// snapshot s (e.g. a ring of snapshots, one per tick).
+func (w *World) SnapshotInto(s *Snapshot) {
+	if s.world != w {
+		*s = Snapshot{}
+	}
+	s.world = w
+	s.tick = w.tick
+	s.lastEntity = w.lastEntity
+	s.entities = append(s.entities[:0], w.entities...)
+	s.entityIDs = copyMap(s.entityIDs, w.entityIDs)
+	s.entityUUIDs = copyMap(s.entityUUIDs, w.entityUUIDs)
+	s.names = w.names.copyTo(s.names)
+	if s.components == nil {
+		s.components = make(map[string]interface{}, len(w.components))
+	}
+	for name, c := range w.components {
+		s.components[name] = c.snapshot(s.components[name])
+	}
+	if s.relations == nil {
+		s.relations = make(map[string]interface{}, len(w.relations))
+	}
+	for name, r := range w.relations {
+		s.relations[name] = r.snapshot(s.relations[name])
+	}
+	if w.archetypes != nil {
+		s.archetypes = w.archetypes.snapshot(s.archetypes)
+	}
+	if s.views == nil {
+		s.views = make(map[*viewCommon][]Entity, len(w.views))
+	}
+	for k := range s.views {
+		if _, ok := getViewIndex(w.views, k); !ok {
+			delete(s.views, k)
+		}
+	}
+	for _, vc := range w.views {
+		s.views[vc] = append(s.views[vc][:0], vc.entities...)
+	}
+	if len(w.resources) > 0 {
+		if s.resources == nil {
+			s.resources = make(map[string]interface{}, len(w.resources))
+		}
+		for key, clone := range w.resources {
+			s.resources[key] = clone(w.data.Get(key))
+		}
+	}
+}
+
+// Restore rewinds the world to the snapshot s. The snapshot can be restored
+// many times. The component watchers are not notified, but the views and
+// the indexes are restored. Component stores, relations and views created
+// after the snapshot are cleared (views are rebuilt).
+func (w *World) Restore(s *Snapshot) error {
+	if s.world != w {
+		return errSnapshotWorld
+	}
+	w.tick = s.tick
+	w.lastEntity = s.lastEntity
+	w.entities = append(w.entities[:0], s.entities...)
+	for _, r := range w.relevances {
+		r.InvalidateAll()
+	}
+	w.entityIDs = copyMap(w.entityIDs, s.entityIDs)
+	w.entityUUIDs = copyMap(w.entityUUIDs, s.entityUUIDs)
+	w.names = s.names.copyTo(w.names)
+	if w.archetypes != nil {
+		w.archetypes.restore(s.archetypes)
+	}
+	for name, c := range w.components {
+		c.restore(s.components[name])
+	}
+	for name, r := range w.relations {
+		r.restore(s.relations[name])
+	}
+	for _, vc := range w.views {
+		if ents, ok := s.views[vc]; ok {
+			vc.entities = append(vc.entities[:0], ents...)
+			continue
+		}
+		// the view was created after the snapshot
+		vc.entities = append(vc.entities[:0], w.entities...)
+		for _, c := range vc.stores {
+			vc.entities = c.containing(vc.entities)
+		}
+	}
+	for key, clone := range w.resources {
+		if v, ok := s.resources[key]; ok {
+			w.data.Set(key, clone(v))
+		}
+	}
+	return nil
+}
+
+// SnapshotResource adds the world data (see World.Data) with the key to the
+// snapshots. clone must return a copy of the value (it is used when taking
+// and when restoring a snapshot); if clone is nil, the value is copied as is,
+// which is enough for values that are not pointers, maps or slices.
+func (w *World) SnapshotResource(key string, clone func(v interface{}) interface{}) {
+	if clone == nil {
+		clone = func(v interface{}) interface{} {
+			return v
+		}
+	}
+	if w.resources == nil {
+		w.resources = make(map[string]func(v interface{}) interface{})
+	}
+	w.resources[key] = clone
+}
+
+func getViewIndex(views []*viewCommon, vc *viewCommon) (int, bool) {
+	for i, v := range views {
+		if v == vc {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// copyMap copies src into dst (reused if not nil) and returns dst.
+func copyMap[K comparable, V any](dst, src map[K]V) map[K]V {
+	if dst == nil {
+		dst = make(map[K]V, len(src))
+	}
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// copyTo copies the names into dst (reusing its memory) and returns dst.
+func (n *entityNames) copyTo(dst entityNames) entityNames {
+	dst.names = copyMap(dst.names, n.names)
+	if dst.byName == nil {
+		dst.byName = make(map[string][]Entity, len(n.byName))
+	}
+	for k := range dst.byName {
+		if _, ok := n.byName[k]; !ok {
+			delete(dst.byName, k)
+		}
+	}
+	for k, ents := range n.byName {
+		dst.byName[k] = append(dst.byName[k][:0], ents...)
+	}
+	dst.unique = n.unique
+	return dst
+}
+
+func (c *ComponentStore[T]) snapshot(dst interface{}) interface{} {
+	if c.backend != nil {
+		return c.backend.snapshot(dst)
+	}
+	d, _ := dst.([]ComponentData[T])
+	return append(d[:0], c.data...)
+}
+
+func (c *ComponentStore[T]) restore(s interface{}) {
+	if c.backend != nil {
+		c.backend.restore(s)
+	} else {
+		d, _ := s.([]ComponentData[T])
+		var zv ComponentData[T]
+		for i := len(d); i < len(c.data); i++ {
+			c.data[i] = zv
+		}
+		c.data = append(c.data[:0], d...)
+	}
+	for _, idx := range c.indexes {
+		idx.indexRebuild()
+	}
+}
+
+type sparseSetSnapshot[T ComponentType] struct {
+	dense    []T
+	entities []Entity
+}
+
+func (b *sparseSetBackend[T]) snapshot(dst interface{}) interface{} {
+	d, _ := dst.(*sparseSetSnapshot[T])
+	if d == nil {
+		d = &sparseSetSnapshot[T]{}
+	}
+	d.dense = append(d.dense[:0], b.dense...)
+	d.entities = append(d.entities[:0], b.entities...)
+	return d
+}
+
+func (b *sparseSetBackend[T]) restore(s interface{}) {
+	for _, e := range b.entities {
+		b.sparse[e>>sparsePageBits][e&sparsePageMask] = 0
+	}
+	d, _ := s.(*sparseSetSnapshot[T])
+	if d == nil {
+		d = &sparseSetSnapshot[T]{}
+	}
+	var zv T
+	for i := len(d.dense); i < len(b.dense); i++ {
+		b.dense[i] = zv
+	}
+	b.dense = append(b.dense[:0], d.dense...)
+	b.entities = append(b.entities[:0], d.entities...)
+	for i, e := range b.entities {
+		b.setIndex(e, i)
+	}
+}
+
+// the archetype tables are restored by the world (see archetypeStorage)
+func (b *archetypeBackend[T]) snapshot(dst interface{}) interface{} {
+	return b.count
+}
+
+func (b *archetypeBackend[T]) restore(s interface{}) {
+	b.count, _ = s.(int)
+}
+
+func (c *archetypeColumnData[T]) copyTo(dst archetypeColumn) archetypeColumn {
+	d, _ := dst.(*archetypeColumnData[T])
+	if d == nil {
+		d = &archetypeColumnData[T]{}
+	}
+	var zv T
+	for i := len(c.data); i < len(d.data); i++ {
+		d.data[i] = zv
+	}
+	d.data = append(d.data[:0], c.data...)
+	return d
+}
+
+func (c *archetypeColumnData[T]) reset() {
+	var zv T
+	for i := range c.data {
+		c.data[i] = zv
+	}
+	c.data = c.data[:0]
+}
+
+// archetypeSnapshot holds the tables of an archetypeStorage. The archetypes
+// are never removed, so the tables are stored in the same order as
+// archetypeStorage.archetypes. The entity locations are rebuilt from the
+// tables on restore.
+type archetypeSnapshot struct {
+	tables []archetypeTableSnapshot
+}
+
+type archetypeTableSnapshot struct {
+	entities []Entity
+	columns  map[int]archetypeColumn
+}
+
+func (s *archetypeStorage) snapshot(dst *archetypeSnapshot) *archetypeSnapshot {
+	if dst == nil {
+		dst = &archetypeSnapshot{}
+	}
+	for len(dst.tables) < len(s.archetypes) {
+		dst.tables = append(dst.tables, archetypeTableSnapshot{
+			columns: make(map[int]archetypeColumn),
+		})
+	}
+	dst.tables = dst.tables[:len(s.archetypes)]
+	for i, a := range s.archetypes {
+		t := &dst.tables[i]
+		t.entities = append(t.entities[:0], a.entities...)
+		for cid, col := range a.columns {
+			t.columns[cid] = col.copyTo(t.columns[cid])
+		}
+	}
+	return dst
+}
+
+func (s *archetypeStorage) restore(snap *archetypeSnapshot) {
+	if snap == nil {
+		snap = &archetypeSnapshot{}
+	}
+	for e := range s.locations {
+		delete(s.locations, e)
+	}
+	for i, a := range s.archetypes {
+		if i >= len(snap.tables) {
+			// created after the snapshot
+			a.entities = a.entities[:0]
+			for _, col := range a.columns {
+				col.reset()
+			}
+			continue
+		}
+		t := &snap.tables[i]
+		a.entities = append(a.entities[:0], t.entities...)
+		for cid, col := range t.columns {
+			col.copyTo(a.columns[cid])
+		}
+		if a == s.root {
+			continue
+		}
+		for row, e := range a.entities {
+			s.locations[e] = archetypeLocation{arch: a, row: row}
+		}
+	}
+}
+
+type relationSnapshot[R RelationType] struct {
+	sources []Entity
+	targets []Entity
+	data    []R
+}
+
+func (s *RelationStore[R]) snapshot(dst interface{}) interface{} {
+	d, _ := dst.(*relationSnapshot[R])
+	if d == nil {
+		d = &relationSnapshot[R]{}
+	}
+	d.sources = d.sources[:0]
+	d.targets = d.targets[:0]
+	d.data = d.data[:0]
+	for source, t := range s.targets {
+		for target, data := range t {
+			d.sources = append(d.sources, source)
+			d.targets = append(d.targets, target)
+			d.data = append(d.data, data)
+		}
+	}
+	return d
+}
+
+func (s *RelationStore[R]) restore(snap interface{}) {
+	s.targets = make(map[Entity]map[Entity]R)
+	s.sources = make(map[Entity]map[Entity]struct{})
+	s.count = 0
+	d, _ := snap.(*relationSnapshot[R])
+	if d == nil {
+		return
+	}
+	for i := range d.sources {
+		s.relate(d.sources[i], d.targets[i], d.data[i])
+	}
+}
@@ -0,0 +1,109 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type snapshotScore struct {
+	Points int
+}
+
+// worldState returns the entities and their components, names and relations
+func worldState(w *World) map[Entity][]interface{} {
+	state := make(map[Entity][]interface{})
+	for _, e := range w.entities {
+		items := []interface{}{w.Name(e), w.EntityUUID(e), Targets[ownedBy](w, e)}
+		for _, c := range w.ComponentsOf(e) {
+			items = append(items, c.Data)
+		}
+		state[e] = items
+	}
+	return state
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	for _, storage := range []StorageKind{StorageSorted, StorageArchetype, StorageSparse} {
+		w := NewWorldWithOptions(WorldOptions{Storage: storage, Empty: true})
+		ents := populateStorageTestWorld(w, 100)
+		Set(w, ents[1], sparseStatus{Stunned: true})
+		Relate(w, ents[1], ents[0], ownedBy{Since: 1})
+		assert.NoError(t, w.SetName(ents[0], "player"))
+		w.Data().Set("score", snapshotScore{Points: 10})
+		w.SnapshotResource("score", nil)
+		view := NewView2[BenchPos3, BenchSpeed3](w, nil, nil)
+		idx := NewIndex(w, func(d *BenchPos3) float64 { return d.X })
+		state := worldState(w)
+		viewEnts := append([]Entity(nil), view.entities...)
+
+		s := w.Snapshot()
+		for i := 0; i < 2; i++ {
+			w.Remove(ents[0])
+			RemoveComponent[BenchSpeed3](w, ents[2])
+			Set(w, ents[3], BenchSpeed3{Xs: 5})
+			Set(w, ents[4], BenchPos3{X: -1})
+			e := w.NewEntity()
+			Set(w, e, BenchPos3{})
+			Set(w, e, BenchSpeed3{})
+			Set(w, e, sparseStatus{})
+			w.Data().Set("score", snapshotScore{Points: 20})
+			later := NewView[sparseStatus](w, nil, nil)
+			w.tick++
+
+			assert.NoError(t, w.Restore(s))
+			assert.Equal(t, state, worldState(w), storage)
+			assert.Equal(t, viewEnts, view.entities, storage)
+			assert.Equal(t, []Entity{ents[1]}, later.entities, storage)
+			assert.Equal(t, snapshotScore{Points: 10}, w.Data().Get("score"))
+			first, _ := idx.First(4)
+			assert.Equal(t, ents[4], first)
+			assert.Equal(t, uint64(0), w.Tick())
+			later.Destroy()
+			// the new entities don't reuse IDs
+			assert.Equal(t, ents[len(ents)-1]+1, w.NewEntity())
+			assert.NoError(t, w.Restore(s))
+		}
+		assert.ErrorIs(t, NewEmptyWorld().Restore(s), errSnapshotWorld)
+	}
+}
+
+func benchmarkSnapshot(b *testing.B, storage StorageKind, restore bool) {
+	w := NewWorldWithOptions(WorldOptions{Storage: storage, Empty: true})
+	populateStorageTestWorld(w, 10000)
+	view := NewView4[BenchPos3, BenchSpeed3, BenchAccel, BenchDeltaSpeed](w, nil, nil)
+	defer view.Destroy()
+	s := w.Snapshot()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if restore {
+			_ = w.Restore(s)
+		} else {
+			w.SnapshotInto(s)
+		}
+	}
+}
+
+func BenchmarkSnapshot10kSorted(b *testing.B) {
+	benchmarkSnapshot(b, StorageSorted, false)
+}
+
+func BenchmarkRestore10kSorted(b *testing.B) {
+	benchmarkSnapshot(b, StorageSorted, true)
+}
+
+func BenchmarkSnapshot10kArchetype(b *testing.B) {
+	benchmarkSnapshot(b, StorageArchetype, false)
+}
+
+func BenchmarkRestore10kArchetype(b *testing.B) {
+	benchmarkSnapshot(b, StorageArchetype, true)
+}
+
+func BenchmarkSnapshot10kSparse(b *testing.B) {
+	benchmarkSnapshot(b, StorageSparse, false)
+}
+
+func BenchmarkRestore10kSparse(b *testing.B) {
+	benchmarkSnapshot(b, StorageSparse, true)
+}
@@ -0,0 +1,186 @@
+package ecs
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	sparsePageBits = 10
+	sparsePageSize = 1 << sparsePageBits
+	sparsePageMask = sparsePageSize - 1
+)
+
+var (
+	componentStorages = struct {
+		lock  sync.RWMutex
+		kinds map[string]StorageKind
+	}{
+		kinds: make(map[string]StorageKind),
+	}
+)
+
+// SetComponentStorage sets the storage backend of the component type T,
+// overriding the storage of the world (see WorldOptions). It only affects the
+// component stores created after the call, so it is usually called at init
+// time.
+func SetComponentStorage[T ComponentType](kind StorageKind) {
+	var zv T
+	componentStorages.lock.Lock()
+	defer componentStorages.lock.Unlock()
+	componentStorages.kinds[zv.Pkg()] = kind
+}
+
+// componentStorage returns the storage kind of a component by its registry
+// name.
+func componentStorage(name string, def StorageKind) StorageKind {
+	componentStorages.lock.RLock()
+	defer componentStorages.lock.RUnlock()
+	if kind, ok := componentStorages.kinds[name]; ok {
+		return kind
+	}
+	return def
+}
+
+// newComponentBackend returns the backend of a new component store of T (or
+// nil for StorageSorted).
+func newComponentBackend[T ComponentType](w *World) componentBackend[T] {
+	var zv T
+	switch componentStorage(zv.Pkg(), w.storage) {
+	case StorageArchetype:
+		if w.archetypes == nil {
+			w.archetypes = newArchetypeStorage()
+		}
+		return newArchetypeBackend[T](w.archetypes)
+	case StorageSparse:
+		return newSparseSetBackend[T]()
+	}
+	return nil
+}
+
+// sparseSetBackend is the componentBackend of StorageSparse. The data is
+// packed in dense; sparse maps an entity to its index in dense (+1, so zero
+// means absent). The sparse index is split in pages that are allocated on
+// demand.
+type sparseSetBackend[T ComponentType] struct {
+	dense    []T
+	entities []Entity
+	sparse   [][]int
+}
+
+func newSparseSetBackend[T ComponentType]() *sparseSetBackend[T] {
+	return &sparseSetBackend[T]{
+		dense:    make([]T, 0, 64),
+		entities: make([]Entity, 0, 64),
+		sparse:   make([][]int, 0, 8),
+	}
+}
+
+func (b *sparseSetBackend[T]) index(e Entity) (int, bool) {
+	page := int(e >> sparsePageBits)
+	if page >= len(b.sparse) || b.sparse[page] == nil {
+		return 0, false
+	}
+	i := b.sparse[page][e&sparsePageMask]
+	return i - 1, i != 0
+}
+
+func (b *sparseSetBackend[T]) setIndex(e Entity, i int) {
+	page := int(e >> sparsePageBits)
+	for page >= len(b.sparse) {
+		b.sparse = append(b.sparse, nil)
+	}
+	if b.sparse[page] == nil {
+		b.sparse[page] = make([]int, sparsePageSize)
+	}
+	b.sparse[page][e&sparsePageMask] = i + 1
+}
+
+func (b *sparseSetBackend[T]) get(e Entity) *T {
+	i, ok := b.index(e)
+	if !ok {
+		return nil
+	}
+	return &b.dense[i]
+}
+
+func (b *sparseSetBackend[T]) insert(e Entity, data T) {
+	b.dense = append(b.dense, data)
+	b.entities = append(b.entities, e)
+	b.setIndex(e, len(b.dense)-1)
+}
+
+func (b *sparseSetBackend[T]) remove(e Entity) bool {
+	i, ok := b.index(e)
+	if !ok {
+		return false
+	}
+	last := len(b.dense) - 1
+	if i != last {
+		b.dense[i] = b.dense[last]
+		b.entities[i] = b.entities[last]
+		b.setIndex(b.entities[i], i)
+	}
+	var zv T
+	b.dense[last] = zv
+	b.dense = b.dense[:last]
+	b.entities = b.entities[:last]
+	b.sparse[e>>sparsePageBits][e&sparsePageMask] = 0
+	return true
+}
+
+// removeMany swaps out the entities one by one if they are a few, or clears
+// their indexes and compacts dense once otherwise.
+func (b *sparseSetBackend[T]) removeMany(ents []Entity) {
+	if len(ents) < len(b.dense)/8 {
+		for _, e := range ents {
+			b.remove(e)
+		}
+		return
+	}
+	for _, e := range ents {
+		b.sparse[e>>sparsePageBits][e&sparsePageMask] = 0
+	}
+	n := 0
+	for i, e := range b.entities {
+		if _, ok := b.index(e); !ok {
+			continue
+		}
+		if i != n {
+			b.dense[n] = b.dense[i]
+			b.entities[n] = e
+			b.setIndex(e, n)
+		}
+		n++
+	}
+	var zv T
+	for i := n; i < len(b.dense); i++ {
+		b.dense[i] = zv
+	}
+	b.dense = b.dense[:n]
+	b.entities = b.entities[:n]
+}
+
+func (b *sparseSetBackend[T]) len() int {
+	return len(b.dense)
+}
+
+func (b *sparseSetBackend[T]) sorted() []ComponentData[T] {
+	result := make([]ComponentData[T], len(b.dense))
+	for i := range b.dense {
+		result[i] = ComponentData[T]{
+			Entity: b.entities[i],
+			Data:   b.dense[i],
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Entity < result[j].Entity
+	})
+	return result
+}
+
+func (b *sparseSetBackend[T]) each(fn func(e Entity, d *T)) {
+	for i := range b.dense {
+		fn(b.entities[i], &b.dense[i])
+	}
+}
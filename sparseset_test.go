@@ -0,0 +1,129 @@
+package ecs
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sparseStatus struct {
+	Stunned bool
+}
+
+func (sparseStatus) Pkg() string {
+	return "test.sparseStatus"
+}
+
+func init() {
+	SetComponentStorage[sparseStatus](StorageSparse)
+}
+
+func TestSparseSetStorage(t *testing.T) {
+	w := NewEmptyWorld()
+	ents := make([]Entity, 0, 3000)
+	for i := 0; i < 3000; i++ {
+		e := w.NewEntity()
+		ents = append(ents, e)
+		Set(w, e, BenchPos3{X: float64(i)})
+		if i%3 == 0 {
+			Set(w, e, sparseStatus{Stunned: true})
+		}
+	}
+	store := GetComponentStore[sparseStatus](w)
+	_, ok := store.backend.(*sparseSetBackend[sparseStatus])
+	assert.True(t, ok)
+	assert.Equal(t, 1000, store.Len())
+
+	view := NewView2[BenchPos3, sparseStatus](w, nil, nil)
+	defer view.Destroy()
+	assert.Equal(t, 1000, view.Len())
+
+	// toggle
+	for i := 0; i < 3000; i += 3 {
+		if i%2 == 0 {
+			assert.True(t, RemoveComponent[sparseStatus](w, ents[i]))
+		}
+	}
+	assert.False(t, RemoveComponent[sparseStatus](w, ents[0]))
+	assert.Equal(t, 500, store.Len())
+	assert.Equal(t, 500, view.Len())
+	Set(w, ents[1], sparseStatus{})
+	assert.Equal(t, 501, view.Len())
+	assert.True(t, w.Remove(ents[3]))
+	assert.False(t, Contains[sparseStatus](w, ents[3]))
+
+	result := make([]Entity, 0)
+	view.Each(func(e Entity, p *BenchPos3, s *sparseStatus) {
+		assert.Equal(t, float64(e-1), p.X)
+		result = append(result, e)
+	})
+	assert.Equal(t, 500, len(result))
+	assert.True(t, sort.SliceIsSorted(result, func(i, j int) bool {
+		return result[i] < result[j]
+	}))
+	all := store.all()
+	assert.Equal(t, 500, len(all))
+	assert.Equal(t, ents[1], all[0].Entity)
+	assert.True(t, Apply(w, ents[9], func(s *sparseStatus) {
+		assert.True(t, s.Stunned)
+	}))
+
+	// batch removal compacts the dense data
+	assert.Equal(t, 1499, w.RemoveMany(ents[:1500])) // ents[3] was removed
+	assert.Equal(t, 250, store.Len())
+	assert.Equal(t, 250, view.Len())
+	n := 0
+	view.Each(func(e Entity, p *BenchPos3, s *sparseStatus) {
+		assert.Equal(t, float64(e-1), p.X)
+		assert.True(t, s.Stunned)
+		n++
+	})
+	assert.Equal(t, 250, n)
+	assert.False(t, Contains[sparseStatus](w, ents[9]))
+
+	// mixed with archetype storage
+	aw := NewWorldWithOptions(WorldOptions{Storage: StorageArchetype, Empty: true})
+	e := aw.NewEntity()
+	Set(aw, e, BenchPos3{X: 1})
+	Set(aw, e, sparseStatus{Stunned: true})
+	n = 0
+	NewView2[BenchPos3, sparseStatus](aw, nil, nil).Each(func(e Entity, p *BenchPos3, s *sparseStatus) {
+		n++
+		assert.True(t, s.Stunned)
+	})
+	assert.Equal(t, 1, n)
+}
+
+type benchToggle struct {
+	On bool
+}
+
+func (benchToggle) Pkg() string {
+	return "test.benchToggle"
+}
+
+func benchmarkToggle[T ComponentType](b *testing.B, v T) {
+	b.StopTimer()
+	w := NewEmptyWorld()
+	ents := make([]Entity, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		e := w.NewEntity()
+		ents = append(ents, e)
+		Set(w, e, v)
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		e := ents[(i*7919)%len(ents)]
+		RemoveComponent[T](w, e)
+		Set(w, e, v)
+	}
+}
+
+func BenchmarkToggleSorted(b *testing.B) {
+	benchmarkToggle(b, benchToggle{On: true})
+}
+
+func BenchmarkToggleSparse(b *testing.B) {
+	benchmarkToggle(b, sparseStatus{Stunned: true})
+}
@@ -0,0 +1,401 @@
+package ecs
+
+import (
+	"math"
+	"sort"
+)
+
+type spatialCell [3]int64
+
+type spatialEntry struct {
+	pos  [3]float64
+	cell spatialCell
+}
+
+// spatialHash is a uniform grid of entities by position. It is the common
+// implementation of SpatialHash2D and SpatialHash3D.
+type spatialHash[T ComponentType] struct {
+	store    *ComponentStore[T]
+	pos      func(d *T) [3]float64
+	dims     int
+	cellSize float64
+	cells    map[spatialCell][]Entity
+	entries  map[Entity]spatialEntry
+}
+
+func newSpatialHash[T ComponentType](w *World, dims int, cellSize float64, pos func(d *T) [3]float64) *spatialHash[T] {
+	if cellSize <= 0 {
+		panic("ecs: the cell size of a spatial hash must be > 0")
+	}
+	h := &spatialHash[T]{
+		store:    GetComponentStore[T](w),
+		pos:      pos,
+		dims:     dims,
+		cellSize: cellSize,
+	}
+	h.store.indexes = append(h.store.indexes, h)
+	h.rebuild()
+	return h
+}
+
+func (h *spatialHash[T]) cellOf(p [3]float64) spatialCell {
+	var c spatialCell
+	for i := 0; i < h.dims; i++ {
+		c[i] = int64(math.Floor(p[i] / h.cellSize))
+	}
+	return c
+}
+
+func (h *spatialHash[T]) indexSet(e Entity, d *T) {
+	p := h.pos(d)
+	c := h.cellOf(p)
+	if old, ok := h.entries[e]; ok {
+		if old.cell == c {
+			h.entries[e] = spatialEntry{p, c}
+			return
+		}
+		h.removeFromCell(e, old.cell)
+	}
+	h.entries[e] = spatialEntry{p, c}
+	h.cells[c] = append(h.cells[c], e)
+}
+
+func (h *spatialHash[T]) indexRemove(e Entity) {
+	if old, ok := h.entries[e]; ok {
+		h.removeFromCell(e, old.cell)
+		delete(h.entries, e)
+	}
+}
+
+func (h *spatialHash[T]) indexRebuild() {
+	h.rebuild()
+}
+
+func (h *spatialHash[T]) removeFromCell(e Entity, c spatialCell) {
+	ents := RemoveEntityFromSlice(h.cells[c], e)
+	if len(ents) == 0 {
+		delete(h.cells, c)
+		return
+	}
+	h.cells[c] = ents
+}
+
+func (h *spatialHash[T]) update(e Entity) {
+	if x := h.store.ptr(e); x != nil {
+		h.indexSet(e, x)
+	} else {
+		h.indexRemove(e)
+	}
+}
+
+func (h *spatialHash[T]) rebuild() {
+	h.cells = make(map[spatialCell][]Entity)
+	h.entries = make(map[Entity]spatialEntry)
+	h.sync()
+}
+
+func (h *spatialHash[T]) sync() {
+	all := h.store.all()
+	for i := range all {
+		h.indexSet(all[i].Entity, &all[i].Data)
+	}
+}
+
+func (h *spatialHash[T]) destroy() {
+	h.store.removeIndex(h)
+	h.cells = nil
+	h.entries = nil
+}
+
+func (h *spatialHash[T]) dist2(a, b [3]float64) float64 {
+	d := 0.0
+	for i := 0; i < h.dims; i++ {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return d
+}
+
+// box calls fn for each entity in the cells that intersect the box min-max.
+func (h *spatialHash[T]) box(min, max [3]float64, fn func(e Entity, p [3]float64)) {
+	c0, c1 := h.cellOf(min), h.cellOf(max)
+	ncells := 1.0
+	for i := 0; i < h.dims; i++ {
+		ncells *= float64(c1[i]-c0[i]) + 1
+	}
+	if ncells > float64(len(h.cells)) {
+		// the box is larger than the populated grid
+		for c, ents := range h.cells {
+			if cellInBox(c, c0, c1) {
+				for _, e := range ents {
+					fn(e, h.entries[e].pos)
+				}
+			}
+		}
+		return
+	}
+	var c spatialCell
+	for c[0] = c0[0]; c[0] <= c1[0]; c[0]++ {
+		for c[1] = c0[1]; c[1] <= c1[1]; c[1]++ {
+			for c[2] = c0[2]; c[2] <= c1[2]; c[2]++ {
+				for _, e := range h.cells[c] {
+					fn(e, h.entries[e].pos)
+				}
+			}
+		}
+	}
+}
+
+func (h *spatialHash[T]) aabb(min, max [3]float64) []Entity {
+	result := make([]Entity, 0)
+	h.box(min, max, func(e Entity, p [3]float64) {
+		for i := 0; i < h.dims; i++ {
+			if p[i] < min[i] || p[i] > max[i] {
+				return
+			}
+		}
+		result = append(result, e)
+	})
+	SortEntities(result)
+	return result
+}
+
+func (h *spatialHash[T]) radius(center [3]float64, r float64) []Entity {
+	var min, max [3]float64
+	for i := 0; i < h.dims; i++ {
+		min[i] = center[i] - r
+		max[i] = center[i] + r
+	}
+	r2 := r * r
+	result := make([]Entity, 0)
+	h.box(min, max, func(e Entity, p [3]float64) {
+		if h.dist2(center, p) <= r2 {
+			result = append(result, e)
+		}
+	})
+	SortEntities(result)
+	return result
+}
+
+type spatialCandidate struct {
+	e     Entity
+	dist2 float64
+}
+
+// nearest searches the cells in rings around the center until the k nearest
+// entities are found.
+func (h *spatialHash[T]) nearest(center [3]float64, k int) []Entity {
+	if k <= 0 || len(h.entries) == 0 {
+		return []Entity{}
+	}
+	cc := h.cellOf(center)
+	candidates := make([]spatialCandidate, 0, k)
+	visited := 0
+	for ring := int64(0); ; ring++ {
+		if math.Pow(float64(2*ring+1), float64(h.dims)) > float64(len(h.cells)) {
+			// the ring is larger than the populated grid
+			return h.nearestAll(center, k)
+		}
+		h.ring(cc, ring, func(ents []Entity) {
+			for _, e := range ents {
+				candidates = append(candidates, spatialCandidate{e, h.dist2(center, h.entries[e].pos)})
+			}
+			visited += len(ents)
+		})
+		sortSpatialCandidates(candidates)
+		if len(candidates) > k {
+			candidates = candidates[:k]
+		}
+		// the entities outside the ring are at least ring*cellSize away
+		bound := float64(ring) * h.cellSize
+		if visited == len(h.entries) || (len(candidates) == k && candidates[k-1].dist2 <= bound*bound) {
+			break
+		}
+	}
+	return spatialCandidates(candidates)
+}
+
+// nearestAll is the brute force version of nearest.
+func (h *spatialHash[T]) nearestAll(center [3]float64, k int) []Entity {
+	candidates := make([]spatialCandidate, 0, len(h.entries))
+	for e, entry := range h.entries {
+		candidates = append(candidates, spatialCandidate{e, h.dist2(center, entry.pos)})
+	}
+	sortSpatialCandidates(candidates)
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return spatialCandidates(candidates)
+}
+
+// ring calls fn with the entities of each populated cell at the Chebyshev
+// distance "ring" from the cell cc.
+func (h *spatialHash[T]) ring(cc spatialCell, ring int64, fn func(ents []Entity)) {
+	var lo, hi spatialCell
+	for i := 0; i < h.dims; i++ {
+		lo[i] = cc[i] - ring
+		hi[i] = cc[i] + ring
+	}
+	var c spatialCell
+	for c[0] = lo[0]; c[0] <= hi[0]; c[0]++ {
+		for c[1] = lo[1]; c[1] <= hi[1]; c[1]++ {
+			for c[2] = lo[2]; c[2] <= hi[2]; c[2]++ {
+				onEdge := false
+				for i := 0; i < h.dims; i++ {
+					if c[i] == lo[i] || c[i] == hi[i] {
+						onEdge = true
+						break
+					}
+				}
+				if !onEdge {
+					// inner cells were visited by the previous rings
+					c[h.dims-1] = hi[h.dims-1] - 1
+					continue
+				}
+				if ents, ok := h.cells[c]; ok {
+					fn(ents)
+				}
+			}
+		}
+	}
+}
+
+func sortSpatialCandidates(candidates []spatialCandidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist2 != candidates[j].dist2 {
+			return candidates[i].dist2 < candidates[j].dist2
+		}
+		return candidates[i].e < candidates[j].e
+	})
+}
+
+func spatialCandidates(candidates []spatialCandidate) []Entity {
+	result := make([]Entity, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.e
+	}
+	return result
+}
+
+func cellInBox(c, min, max spatialCell) bool {
+	for i := range c {
+		if c[i] < min[i] || c[i] > max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SpatialHash2D is a spatial index (uniform grid) of the entities with the
+// component T, by a 2D position. It is updated by the component store on Set
+// (Replace), Apply and Remove. Positions changed through views or pointers
+// are not tracked; call Update or Sync (e.g. once per frame) after them.
+type SpatialHash2D[T ComponentType] struct {
+	h *spatialHash[T]
+}
+
+// NewSpatialHash2D creates a 2D spatial hash of the component T. cellSize
+// should be close to the usual query radius.
+func NewSpatialHash2D[T ComponentType](w *World, cellSize float64, pos func(d *T) (x, y float64)) *SpatialHash2D[T] {
+	return &SpatialHash2D[T]{
+		h: newSpatialHash(w, 2, cellSize, func(d *T) [3]float64 {
+			x, y := pos(d)
+			return [3]float64{x, y, 0}
+		}),
+	}
+}
+
+// Radius returns the entities (sorted) within the distance r of (x, y).
+func (s *SpatialHash2D[T]) Radius(x, y, r float64) []Entity {
+	return s.h.radius([3]float64{x, y, 0}, r)
+}
+
+// AABB returns the entities (sorted) inside the box (inclusive).
+func (s *SpatialHash2D[T]) AABB(minX, minY, maxX, maxY float64) []Entity {
+	return s.h.aabb([3]float64{minX, minY, 0}, [3]float64{maxX, maxY, 0})
+}
+
+// Nearest returns the k nearest entities to (x, y), sorted by distance.
+func (s *SpatialHash2D[T]) Nearest(x, y float64, k int) []Entity {
+	return s.h.nearest([3]float64{x, y, 0}, k)
+}
+
+// Len returns the number of indexed entities.
+func (s *SpatialHash2D[T]) Len() int {
+	return len(s.h.entries)
+}
+
+// Update updates the position of e with its current component data.
+func (s *SpatialHash2D[T]) Update(e Entity) {
+	s.h.update(e)
+}
+
+// Sync updates the positions of all the entities.
+func (s *SpatialHash2D[T]) Sync() {
+	s.h.sync()
+}
+
+// Rebuild rebuilds the grid from scratch.
+func (s *SpatialHash2D[T]) Rebuild() {
+	s.h.rebuild()
+}
+
+// Destroy stops maintaining the spatial hash.
+func (s *SpatialHash2D[T]) Destroy() {
+	s.h.destroy()
+}
+
+// SpatialHash3D is the 3D version of SpatialHash2D.
+type SpatialHash3D[T ComponentType] struct {
+	h *spatialHash[T]
+}
+
+// NewSpatialHash3D creates a 3D spatial hash of the component T. cellSize
+// should be close to the usual query radius.
+func NewSpatialHash3D[T ComponentType](w *World, cellSize float64, pos func(d *T) (x, y, z float64)) *SpatialHash3D[T] {
+	return &SpatialHash3D[T]{
+		h: newSpatialHash(w, 3, cellSize, func(d *T) [3]float64 {
+			x, y, z := pos(d)
+			return [3]float64{x, y, z}
+		}),
+	}
+}
+
+// Radius returns the entities (sorted) within the distance r of (x, y, z).
+func (s *SpatialHash3D[T]) Radius(x, y, z, r float64) []Entity {
+	return s.h.radius([3]float64{x, y, z}, r)
+}
+
+// AABB returns the entities (sorted) inside the box (inclusive).
+func (s *SpatialHash3D[T]) AABB(minX, minY, minZ, maxX, maxY, maxZ float64) []Entity {
+	return s.h.aabb([3]float64{minX, minY, minZ}, [3]float64{maxX, maxY, maxZ})
+}
+
+// Nearest returns the k nearest entities to (x, y, z), sorted by distance.
+func (s *SpatialHash3D[T]) Nearest(x, y, z float64, k int) []Entity {
+	return s.h.nearest([3]float64{x, y, z}, k)
+}
+
+// Len returns the number of indexed entities.
+func (s *SpatialHash3D[T]) Len() int {
+	return len(s.h.entries)
+}
+
+// Update updates the position of e with its current component data.
+func (s *SpatialHash3D[T]) Update(e Entity) {
+	s.h.update(e)
+}
+
+// Sync updates the positions of all the entities.
+func (s *SpatialHash3D[T]) Sync() {
+	s.h.sync()
+}
+
+// Rebuild rebuilds the grid from scratch.
+func (s *SpatialHash3D[T]) Rebuild() {
+	s.h.rebuild()
+}
+
+// Destroy stops maintaining the spatial hash.
+func (s *SpatialHash3D[T]) Destroy() {
+	s.h.destroy()
+}
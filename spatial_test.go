@@ -0,0 +1,96 @@
+package ecs
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpatialHash2D(t *testing.T) {
+	w := NewEmptyWorld()
+	ents := w.NewEntities(4)
+	SetBatch(w, ents, []BenchPos3{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 40, Y: 30}, {X: -60, Y: -5}})
+	grid := NewSpatialHash2D(w, 16, func(p *BenchPos3) (float64, float64) {
+		return p.X, p.Y
+	})
+	assert.Equal(t, 4, grid.Len())
+	assert.Equal(t, []Entity{ents[0], ents[1]}, grid.Radius(0, 0, 10))
+	assert.Equal(t, []Entity{ents[0], ents[1], ents[2]}, grid.Radius(5, 5, 50))
+	assert.Equal(t, []Entity{ents[1], ents[2]}, grid.AABB(5, -1, 40, 30))
+	assert.Equal(t, []Entity{ents[2], ents[1], ents[0]}, grid.Nearest(30, 30, 3))
+
+	// synchronized by the component store
+	Apply(w, ents[3], func(p *BenchPos3) {
+		p.X = 1
+	})
+	e := w.NewEntity()
+	Set(w, e, BenchPos3{X: 2, Y: 1})
+	w.Remove(ents[1])
+	assert.Equal(t, []Entity{ents[0], ents[3], e}, grid.Radius(0, 0, 10))
+
+	// changes through views need Sync
+	view := NewView[BenchPos3](w, nil, nil)
+	view.Each(func(e Entity, p *BenchPos3) {
+		p.X += 1000
+	})
+	assert.Equal(t, 3, len(grid.Radius(0, 0, 10)))
+	grid.Sync()
+	assert.Equal(t, 0, len(grid.Radius(0, 0, 10)))
+	assert.Equal(t, 4, len(grid.AABB(900, -100, 1100, 100)))
+	grid.Destroy()
+}
+
+func TestSpatialHash3DNearest(t *testing.T) {
+	w := NewEmptyWorld()
+	rnd := rand.New(rand.NewSource(1))
+	pos := make([]BenchPos3, 2000)
+	for i := range pos {
+		pos[i] = BenchPos3{X: rnd.Float64() * 1000, Y: rnd.Float64() * 1000, Z: rnd.Float64() * 100}
+	}
+	ents := w.NewEntities(len(pos))
+	SetBatch(w, ents, pos)
+	grid := NewSpatialHash3D(w, 25, func(p *BenchPos3) (float64, float64, float64) {
+		return p.X, p.Y, p.Z
+	})
+	for q := 0; q < 20; q++ {
+		x, y, z := rnd.Float64()*1000, rnd.Float64()*1000, rnd.Float64()*100
+		dist := func(e Entity) float64 {
+			p := pos[e-ents[0]]
+			return math.Sqrt((p.X-x)*(p.X-x) + (p.Y-y)*(p.Y-y) + (p.Z-z)*(p.Z-z))
+		}
+		expected := make([]Entity, len(ents))
+		copy(expected, ents)
+		sort.Slice(expected, func(i, j int) bool {
+			return dist(expected[i]) < dist(expected[j])
+		})
+		assert.Equal(t, expected[:5], grid.Nearest(x, y, z, 5))
+
+		inside := make([]Entity, 0)
+		for _, e := range ents {
+			if dist(e) <= 60 {
+				inside = append(inside, e)
+			}
+		}
+		assert.Equal(t, inside, grid.Radius(x, y, z, 60))
+	}
+	assert.Equal(t, 2000, len(grid.Nearest(0, 0, 0, 5000)))
+}
+
+func BenchmarkSpatialHashRadius(b *testing.B) {
+	w := NewEmptyWorld()
+	pos := make([]BenchPos3, 10000)
+	for i := range pos {
+		pos[i] = BenchPos3{X: rand.Float64() * 1000, Y: rand.Float64() * 1000}
+	}
+	SetBatch(w, w.NewEntities(len(pos)), pos)
+	grid := NewSpatialHash2D(w, 50, func(p *BenchPos3) (float64, float64) {
+		return p.X, p.Y
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		grid.Radius(500, 500, 50)
+	}
+}
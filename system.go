@@ -257,6 +257,14 @@ var (
 	}
 )
 
+func addGlobalSystems(w *World) {
+	globalSystems.lock.Lock()
+	defer globalSystems.lock.Unlock()
+	for _, sf := range globalSystems.sysFactory {
+		sf(w)
+	}
+}
+
 // RegisterGlobalSystem registers a system to be included on every new world.
 // All worlds initiated with NewWorld() will have this system included.
 func RegisterGlobalSystem[T ComponentType](info GlobalSystemInfo[T]) {
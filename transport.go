@@ -0,0 +1,77 @@
+package ecs
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTransportClosed is returned by a Transport that was closed.
+var ErrTransportClosed = errors.New("transport closed")
+
+// Transport is a message channel between a ReplicationServer and a
+// ReplicationClient. The messages must be delivered reliably and in order,
+// since each delta is relative to the previous one.
+type Transport interface {
+	// Send sends a message to the other end.
+	Send(msg []byte) error
+	// Receive returns the next message, or nil if there is none. It must not
+	// block.
+	Receive() ([]byte, error)
+	// Close closes both ends of the channel.
+	Close() error
+}
+
+// loopbackPipe is the shared state of a pair of loopback transports
+type loopbackPipe struct {
+	lock   sync.Mutex
+	queues [2][][]byte
+	closed bool
+}
+
+type loopbackTransport struct {
+	pipe *loopbackPipe
+	side int
+}
+
+// NewLoopbackTransport returns the two ends of an in-memory transport. It is
+// safe for concurrent use.
+func NewLoopbackTransport() (Transport, Transport) {
+	p := &loopbackPipe{}
+	return &loopbackTransport{p, 0}, &loopbackTransport{p, 1}
+}
+
+func (t *loopbackTransport) Send(msg []byte) error {
+	t.pipe.lock.Lock()
+	defer t.pipe.lock.Unlock()
+	if t.pipe.closed {
+		return ErrTransportClosed
+	}
+	other := 1 - t.side
+	m := make([]byte, len(msg))
+	copy(m, msg)
+	t.pipe.queues[other] = append(t.pipe.queues[other], m)
+	return nil
+}
+
+func (t *loopbackTransport) Receive() ([]byte, error) {
+	t.pipe.lock.Lock()
+	defer t.pipe.lock.Unlock()
+	q := t.pipe.queues[t.side]
+	if len(q) == 0 {
+		if t.pipe.closed {
+			return nil, ErrTransportClosed
+		}
+		return nil, nil
+	}
+	msg := q[0]
+	q[0] = nil
+	t.pipe.queues[t.side] = q[1:]
+	return msg, nil
+}
+
+func (t *loopbackTransport) Close() error {
+	t.pipe.lock.Lock()
+	defer t.pipe.lock.Unlock()
+	t.pipe.closed = true
+	return nil
+}
@@ -3,21 +3,35 @@ package ecs
 type viewCommon struct {
 	world    *World
 	entities []Entity
+	stores   []IComponentStore // the components of the view
+
+	// matched archetypes cache (only used with StorageArchetype)
+	archetypes  []*archetype
+	narchetypes int
 
 	EntityAdded   func(e Entity)
 	EntityRemoved func(e Entity)
 }
 
-func newViewCommon(w *World, onadded, onremoved func(e Entity)) *viewCommon {
-	return &viewCommon{
+func newViewCommon(w *World, onadded, onremoved func(e Entity), stores ...IComponentStore) *viewCommon {
+	vc := &viewCommon{
 		world:         w,
 		entities:      make([]Entity, 0, 512),
+		stores:        stores,
 		EntityAdded:   onadded,
 		EntityRemoved: onremoved,
 	}
+	w.views = append(w.views, vc)
+	return vc
 }
 
 func (vc *viewCommon) destroy() {
+	for i, v := range vc.world.views {
+		if v == vc {
+			vc.world.views = append(vc.world.views[:i], vc.world.views[i+1:]...)
+			break
+		}
+	}
 	vc.world = nil
 	vc.entities = nil
 	vc.EntityAdded = nil
@@ -38,11 +52,15 @@ func (vc *viewCommon) ents() []Entity {
 }
 
 func (vc *viewCommon) onAdded(e Entity) {
-	vc.EntityAdded(e)
+	if vc.EntityAdded != nil {
+		vc.EntityAdded(e)
+	}
 }
 
 func (vc *viewCommon) onRemoved(e Entity) {
-	vc.EntityRemoved(e)
+	if vc.EntityRemoved != nil {
+		vc.EntityRemoved(e)
+	}
 }
 
 func (vc *viewCommon) removeEntityAt(index int) {
@@ -50,7 +68,34 @@ func (vc *viewCommon) removeEntityAt(index int) {
 }
 
 func (vc *viewCommon) addEntityAt(e Entity, index int) {
-	Insert(vc.entities, index, e)
+	vc.entities = Insert(vc.entities, index, e)
+}
+
+// addEntities merges sorted entities into the view. It returns the entities
+// that were not in the view.
+func (vc *viewCommon) addEntities(ents []Entity) []Entity {
+	var added []Entity
+	vc.entities, added = mergeEntities(vc.entities, ents)
+	return added
+}
+
+// removeEntities removes sorted entities from the view. It returns the
+// entities that were in the view.
+func (vc *viewCommon) removeEntities(ents []Entity) []Entity {
+	var removed []Entity
+	vc.entities, removed = subtractEntities(vc.entities, ents)
+	return removed
+}
+
+// matchingArchetypes returns the archetypes that have all the component ids.
+// The result is cached until a new archetype is created.
+func (vc *viewCommon) matchingArchetypes(ids []int) []*archetype {
+	s := vc.world.archetypes
+	if vc.archetypes == nil || vc.narchetypes != len(s.archetypes) {
+		vc.archetypes = s.matching(ids...)
+		vc.narchetypes = len(s.archetypes)
+	}
+	return vc.archetypes
 }
 
 func (vc *viewCommon) entityIndex(e Entity) (int, bool) {
@@ -66,6 +111,8 @@ type Viewer interface {
 	onRemoved(e Entity)
 	removeEntityAt(index int)
 	addEntityAt(e Entity, index int)
+	addEntities(ents []Entity) []Entity
+	removeEntities(ents []Entity) []Entity
 	entityIndex(e Entity) (int, bool)
 }
 
@@ -75,8 +122,21 @@ type View[T ComponentType] struct {
 }
 
 func (v *View[T]) Each(fn func(e Entity, d *T)) {
-	// v.watcher.Component().Each(fn)
-	slc := v.watcher.Component().all()
+	c := v.watcher.Component()
+	if id, ok := c.archetypeID(); ok {
+		for _, a := range v.matchingArchetypes([]int{id}) {
+			ld := archetypeColumnOf[T](a, id)
+			for i, e := range a.entities {
+				fn(e, &ld[i])
+			}
+		}
+		return
+	}
+	if c.backend != nil {
+		c.backend.each(fn)
+		return
+	}
+	slc := c.all()
 	for i := range slc {
 		cd := &slc[i]
 		fn(cd.Entity, &cd.Data)
@@ -114,6 +174,17 @@ func buildWatcherAddedFunc(view Viewer, comps ...IComponentStore) func(e Entity)
 	}
 }
 
+func buildWatcherBatchAddedFunc(view Viewer, comps ...IComponentStore) func(ents []Entity) {
+	return func(ents []Entity) {
+		for _, c := range comps {
+			ents = c.containing(ents)
+		}
+		for _, e := range view.addEntities(ents) {
+			view.onAdded(e)
+		}
+	}
+}
+
 func buildWatcherRemovedFunc(view Viewer) func(e Entity) {
 	return func(e Entity) {
 		if eindex, ok := view.entityIndex(e); ok {
@@ -123,14 +194,24 @@ func buildWatcherRemovedFunc(view Viewer) func(e Entity) {
 	}
 }
 
+func buildWatcherBatchRemovedFunc(view Viewer) func(ents []Entity) {
+	return func(ents []Entity) {
+		for _, e := range view.removeEntities(ents) {
+			view.onRemoved(e)
+		}
+	}
+}
+
 func NewView[T ComponentType](w *World, onadded, onremoved func(e Entity)) *View[T] {
 	cc := GetComponentStore[T](w)
 	view := &View[T]{
-		viewCommon: newViewCommon(w, onadded, onremoved),
+		viewCommon: newViewCommon(w, onadded, onremoved, cc),
 		watcher:    newComponentWatcher(cc),
 	}
 	view.watcher.ComponentAdded = buildWatcherAddedFunc(view)
+	view.watcher.ComponentsAdded = buildWatcherBatchAddedFunc(view)
 	view.watcher.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	// add all pre existing entities
 	for _, cd := range cc.all() {
 		view.entities = append(view.entities, cd.Entity)
@@ -149,14 +230,18 @@ func NewView2[T1 ComponentType, T2 ComponentType](w *World, onadded, onremoved f
 	cc1 := GetComponentStore[T1](w)
 	cc2 := GetComponentStore[T2](w)
 	view := &View2[T1, T2]{
-		viewCommon: newViewCommon(w, onadded, onremoved),
+		viewCommon: newViewCommon(w, onadded, onremoved, cc1, cc2),
 		watcher1:   newComponentWatcher(cc1),
 		watcher2:   newComponentWatcher(cc2),
 	}
 	view.watcher1.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2)
+	view.watcher1.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2)
 	view.watcher2.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2)
+	view.watcher2.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2)
 	view.watcher1.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher1.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	view.watcher2.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher2.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	// add all pre existing entities
 	i1 := 0
 	i2 := 0
@@ -199,6 +284,25 @@ func (v *View2[T1, T2]) Destroy() bool {
 func (v *View2[T1, T2]) Each(fn func(e Entity, d1 *T1, d2 *T2)) {
 	c1 := v.watcher1.Component()
 	c2 := v.watcher2.Component()
+	if c1.backend != nil || c2.backend != nil {
+		if ids, ok := archetypeIDs(c1, c2); ok {
+			for _, a := range v.matchingArchetypes(ids) {
+				ld1 := archetypeColumnOf[T1](a, ids[0])
+				ld2 := archetypeColumnOf[T2](a, ids[1])
+				for i, e := range a.entities {
+					fn(e, &ld1[i], &ld2[i])
+				}
+			}
+			return
+		}
+		for _, e := range v.entities {
+			d1, d2 := c1.ptr(e), c2.ptr(e)
+			if d1 != nil && d2 != nil {
+				fn(e, d1, d2)
+			}
+		}
+		return
+	}
 	ld1 := c1.all()
 	len1 := len(ld1)
 	ld2 := c2.all()
@@ -238,17 +342,23 @@ func NewView3[T1 ComponentType, T2 ComponentType, T3 ComponentType](w *World, on
 	cc2 := GetComponentStore[T2](w)
 	cc3 := GetComponentStore[T3](w)
 	view := &View3[T1, T2, T3]{
-		viewCommon: newViewCommon(w, onadded, onremoved),
+		viewCommon: newViewCommon(w, onadded, onremoved, cc1, cc2, cc3),
 		watcher1:   newComponentWatcher(cc1),
 		watcher2:   newComponentWatcher(cc2),
 		watcher3:   newComponentWatcher(cc3),
 	}
 	view.watcher1.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2, cc3)
+	view.watcher1.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2, cc3)
 	view.watcher2.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2, cc3)
+	view.watcher2.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2, cc3)
 	view.watcher3.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2, cc3)
+	view.watcher3.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2, cc3)
 	view.watcher1.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher1.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	view.watcher2.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher2.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	view.watcher3.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher3.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	// add all pre existing entities
 	all1 := cc1.all()
 	all2 := cc2.all()
@@ -296,6 +406,26 @@ func (v *View3[T1, T2, T3]) Each(fn func(e Entity, d1 *T1, d2 *T2, d3 *T3)) {
 	c1 := v.watcher1.Component()
 	c2 := v.watcher2.Component()
 	c3 := v.watcher3.Component()
+	if c1.backend != nil || c2.backend != nil || c3.backend != nil {
+		if ids, ok := archetypeIDs(c1, c2, c3); ok {
+			for _, a := range v.matchingArchetypes(ids) {
+				ld1 := archetypeColumnOf[T1](a, ids[0])
+				ld2 := archetypeColumnOf[T2](a, ids[1])
+				ld3 := archetypeColumnOf[T3](a, ids[2])
+				for i, e := range a.entities {
+					fn(e, &ld1[i], &ld2[i], &ld3[i])
+				}
+			}
+			return
+		}
+		for _, e := range v.entities {
+			d1, d2, d3 := c1.ptr(e), c2.ptr(e), c3.ptr(e)
+			if d1 != nil && d2 != nil && d3 != nil {
+				fn(e, d1, d2, d3)
+			}
+		}
+		return
+	}
 	ld1 := c1.all()
 	len1 := len(ld1)
 	ld2 := c2.all()
@@ -344,20 +474,28 @@ func NewView4[T1 ComponentType, T2 ComponentType, T3 ComponentType, T4 Component
 	cc3 := GetComponentStore[T3](w)
 	cc4 := GetComponentStore[T4](w)
 	view := &View4[T1, T2, T3, T4]{
-		viewCommon: newViewCommon(w, onadded, onremoved),
+		viewCommon: newViewCommon(w, onadded, onremoved, cc1, cc2, cc3, cc4),
 		watcher1:   newComponentWatcher(cc1),
 		watcher2:   newComponentWatcher(cc2),
 		watcher3:   newComponentWatcher(cc3),
 		watcher4:   newComponentWatcher(cc4),
 	}
 	view.watcher1.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2, cc3, cc4)
+	view.watcher1.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2, cc3, cc4)
 	view.watcher2.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2, cc3, cc4)
+	view.watcher2.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2, cc3, cc4)
 	view.watcher3.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2, cc3, cc4)
+	view.watcher3.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2, cc3, cc4)
 	view.watcher4.ComponentAdded = buildWatcherAddedFunc(view, cc1, cc2, cc3, cc4)
+	view.watcher4.ComponentsAdded = buildWatcherBatchAddedFunc(view, cc1, cc2, cc3, cc4)
 	view.watcher1.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher1.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	view.watcher2.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher2.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	view.watcher3.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher3.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	view.watcher4.ComponentRemoved = buildWatcherRemovedFunc(view)
+	view.watcher4.ComponentsRemoved = buildWatcherBatchRemovedFunc(view)
 	// add all pre existing entities
 	all1 := cc1.all()
 	all2 := cc2.all()
@@ -413,6 +551,27 @@ func (v *View4[T1, T2, T3, T4]) Each(fn func(e Entity, d1 *T1, d2 *T2, d3 *T3, d
 	c2 := v.watcher2.Component()
 	c3 := v.watcher3.Component()
 	c4 := v.watcher4.Component()
+	if c1.backend != nil || c2.backend != nil || c3.backend != nil || c4.backend != nil {
+		if ids, ok := archetypeIDs(c1, c2, c3, c4); ok {
+			for _, a := range v.matchingArchetypes(ids) {
+				ld1 := archetypeColumnOf[T1](a, ids[0])
+				ld2 := archetypeColumnOf[T2](a, ids[1])
+				ld3 := archetypeColumnOf[T3](a, ids[2])
+				ld4 := archetypeColumnOf[T4](a, ids[3])
+				for i, e := range a.entities {
+					fn(e, &ld1[i], &ld2[i], &ld3[i], &ld4[i])
+				}
+			}
+			return
+		}
+		for _, e := range v.entities {
+			d1, d2, d3, d4 := c1.ptr(e), c2.ptr(e), c3.ptr(e), c4.ptr(e)
+			if d1 != nil && d2 != nil && d3 != nil && d4 != nil {
+				fn(e, d1, d2, d3, d4)
+			}
+		}
+		return
+	}
 	ld1 := c1.all()
 	len1 := len(ld1)
 	ld2 := c2.all()
@@ -0,0 +1,24 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewAddEntity(t *testing.T) {
+	w := NewWorld()
+	e1 := w.NewEntity()
+	e2 := w.NewEntity()
+	added := 0
+	view := NewView[Position](w, func(e Entity) {
+		added++
+	}, func(e Entity) {})
+	defer view.Destroy()
+	Set(w, e2, Position{X: 2})
+	// e1 goes before e2
+	Set(w, e1, Position{X: 1})
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 2, view.Len())
+	assert.Equal(t, []Entity{e1, e2}, view.ents())
+}
@@ -1,28 +1,47 @@
 package ecs
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/gabstv/container"
 	"github.com/google/uuid"
 )
 
+// ErrEntityNotFound is returned when an entity is not in the world.
+var ErrEntityNotFound = errors.New("entity not found")
+
 type World struct {
 	data         container.Dictionary[string, interface{}]
 	lastEntity   Entity
 	entities     []Entity
 	entityIDs    map[Entity]uuid.UUID // this is used when serializing/deserializing data
 	entityUUIDs  map[uuid.UUID]Entity
+	names        entityNames
 	eventManager *eventManager
+	eventQueues  map[string]eventQueueSwapper
 	components   map[string]IComponentStore
+	relations    map[string]IRelationStore
+	storage      StorageKind       // default storage of new component stores
+	archetypes   *archetypeStorage // only set if the storage is StorageArchetype
 	systems      []ISystem
 	sysMap       map[int]ISystem
 	sysid        int
 	isloading    bool
+	decoding     bool              // see beginDecoding
+	codecLocked  bool              // see lockCodec
+	loadErrors   DeserializeErrors // problems found by the last load
 	enabled      bool
+	tick         uint64
+	inspector    *Inspector
+	history      *History
+	views        []*viewCommon
+	relevances   []*Relevance
+	resources    map[string]func(v interface{}) interface{} // see SnapshotResource
 }
 
 func (w *World) Data() *container.Dictionary[string, interface{}] {
@@ -33,6 +52,11 @@ func (w *World) IsLoading() bool {
 	return w.isloading
 }
 
+// Tick returns the number of times the world was stepped (Step or StepF).
+func (w *World) Tick() uint64 {
+	return w.tick
+}
+
 // EntityUUID returns the UUID of the entity
 // If the entity exists, but no UUID is set, a new UUID is generated and set
 func (w *World) EntityUUID(e Entity) uuid.UUID {
@@ -64,9 +88,19 @@ func (w *World) getEntityByUUID(id uuid.UUID) Entity {
 		return e
 	}
 	// create a new entity and set the uuid to it
-	e := w.NewEntity()
+	w.lastEntity++
+	e := w.lastEntity
+	w.entities = append(w.entities, e)
 	w.entityUUIDs[id] = e
 	w.entityIDs[e] = id
+	if w.decoding {
+		// recorded if the decoded data is kept (see endDecoding)
+		return e
+	}
+	w.invalidateRelevance(e)
+	if h := w.recording(); h != nil {
+		h.recordSpawn(e)
+	}
 	return e
 }
 
@@ -80,11 +114,40 @@ func (w *World) EntityByUUID(id uuid.UUID) (Entity, bool) {
 }
 
 func (w *World) NewEntity() Entity {
+	e := w.newEntity()
+	if h := w.recording(); h != nil {
+		h.recordSpawn(e)
+	}
+	return e
+}
+
+func (w *World) newEntity() Entity {
 	w.lastEntity++
 	w.entities = append(w.entities, w.lastEntity)
+	w.invalidateRelevance(w.lastEntity)
 	return w.lastEntity
 }
 
+// NewEntities creates n new entities at once. Use it with SetBatch to spawn
+// many entities.
+func (w *World) NewEntities(n int) []Entity {
+	ents := make([]Entity, n)
+	for i := range ents {
+		w.lastEntity++
+		ents[i] = w.lastEntity
+	}
+	w.entities = append(w.entities, ents...)
+	w.invalidateRelevance(ents...)
+	if h := w.recording(); h != nil {
+		h.Begin("")
+		for _, e := range ents {
+			h.recordSpawn(e)
+		}
+		h.End()
+	}
+	return ents
+}
+
 // Remove removes an Entity. It tries to delete the entity from all the
 // component registries of this world.
 func (w *World) Remove(e Entity) bool {
@@ -95,13 +158,98 @@ func (w *World) Remove(e Entity) bool {
 		return false
 	}
 	// x is present at data[i]
-	for _, c := range w.components {
-		_ = c.Remove(e)
+	h := w.recording()
+	if h != nil {
+		h.Begin("")
+		defer h.End()
 	}
+	w.removeComponents([]Entity{e})
 	w.entities = append(w.entities[:x], w.entities[x+1:]...)
+	w.forgetEntity(e, h)
 	return true
 }
 
+// hasEntity returns true if e is in the world
+func (w *World) hasEntity(e Entity) bool {
+	_, ok := getEntityIndex(w.entities, e)
+	return ok
+}
+
+// RemoveMany removes many entities at once. Each component store and view is
+// compacted only once. It returns the number of entities removed.
+func (w *World) RemoveMany(ents []Entity) int {
+	sorted := make([]Entity, len(ents))
+	copy(sorted, ents)
+	SortEntities(sorted)
+	var removed []Entity
+	w.entities, removed = subtractEntities(w.entities, sorted)
+	if len(removed) == 0 {
+		return 0
+	}
+	h := w.recording()
+	if h != nil {
+		h.Begin("")
+		defer h.End()
+	}
+	w.removeComponents(removed)
+	for _, e := range removed {
+		w.forgetEntity(e, h)
+	}
+	return len(removed)
+}
+
+// forgetEntity drops the relations, name, UUID and listeners of a removed
+// entity, records its despawn in h (if not nil) and marks it as dirty in the
+// relevances of the world.
+func (w *World) forgetEntity(e Entity, h *History) {
+	if h != nil {
+		h.recordDespawn(e)
+	}
+	w.removeRelations(e)
+	w.names.remove(e)
+	if id, ok := w.entityIDs[e]; ok {
+		delete(w.entityUUIDs, id)
+		delete(w.entityIDs, e)
+	}
+	w.eventManager.removeEntity(e)
+	w.invalidateRelevance(e)
+}
+
+// removeComponents removes all the component data of the entities (sorted).
+// With StorageArchetype, each entity leaves the archetype tables at once
+// instead of moving through a table per component.
+func (w *World) removeComponents(ents []Entity) {
+	stores := make([]IComponentStore, 0, len(w.components))
+	removed := make([][]Entity, 0, len(w.components))
+	for _, c := range w.components {
+		if r := c.containing(ents); len(r) > 0 {
+			c.recordRemoved(r)
+			stores = append(stores, c)
+			removed = append(removed, r)
+		}
+	}
+	if w.archetypes != nil {
+		w.archetypes.drop(ents)
+	}
+	for i, c := range stores {
+		c.removeMany(removed[i])
+	}
+}
+
+// Clear removes all the entities of the world (see RemoveMany). The systems
+// and the component stores are kept. New entities don't reuse the IDs of
+// the removed entities.
+func (w *World) Clear() {
+	ents := make([]Entity, len(w.entities))
+	copy(ents, w.entities)
+	w.RemoveMany(ents)
+	unique := w.names.unique
+	w.names = newEntityNames()
+	w.names.unique = unique
+	w.entityIDs = make(map[Entity]uuid.UUID)
+	w.entityUUIDs = make(map[uuid.UUID]Entity)
+}
+
 func (w *World) RemoveSystem(id int) bool {
 	if sys, ok := w.sysMap[id]; ok {
 		delete(w.sysMap, id)
@@ -120,20 +268,56 @@ func (w *World) RemoveSystem(id int) bool {
 	return false
 }
 
+// Step runs all the systems once. The event queues (see EventQueue) are
+// swapped before running the systems.
 func (w *World) Step() {
+	w.tick++
+	w.UpdateEvents()
+	if w.inspector != nil {
+		w.inspector.Poll()
+	}
 	for _, sys := range w.systems {
-		sys.Execute()
+		w.execute(sys)
+	}
+}
+
+// UpdateEvents swaps the buffers of the event queues (see EventQueue). Step
+// calls it before running the systems.
+func (w *World) UpdateEvents() {
+	for _, q := range w.eventQueues {
+		q.swap()
 	}
 }
 
+// StepF runs all the systems that match the flag once. StepF may run several
+// times per frame (e.g. fixed steps), so the event queues (see EventQueue) are
+// not swapped; call UpdateEvents once per frame if the world is not stepped
+// with Step.
 func (w *World) StepF(flag int) {
+	w.tick++
+	if w.inspector != nil {
+		w.inspector.Poll()
+	}
 	for _, sys := range w.systems {
 		if sys.Flag()&flag != 0 {
-			sys.Execute()
+			w.execute(sys)
 		}
 	}
 }
 
+// execute runs a system (and records its timing if there is an inspector).
+// The events fired by the system are not recorded (see EventRecorder).
+func (w *World) execute(sys ISystem) {
+	defer w.eventManager.enter()()
+	if w.inspector == nil {
+		sys.Execute()
+		return
+	}
+	t := time.Now()
+	sys.Execute()
+	w.inspector.recordTiming(sys.ID(), time.Since(t))
+}
+
 func (w *World) Enabled() bool {
 	return w.enabled
 }
@@ -154,26 +338,57 @@ func (w *World) MarshalTo(dw io.Writer) error {
 	return w.serializeData(toml.NewEncoder(dw))
 }
 
+// UnmarshalFrom loads the world data from a reader (see MarshalTo). Invalid
+// data is skipped (see LoadErrors); use UnmarshalFromMode to change this
+// behavior.
 func (w *World) UnmarshalFrom(dr io.Reader) error {
+	return w.UnmarshalFromMode(dr, DeserializeLenient)
+}
+
+// UnmarshalFromMode loads the world data from a reader. In strict mode, if any
+// component or entity is invalid, nothing is loaded and the returned error is
+// a DeserializeErrors. In lenient mode, the invalid data is skipped and the
+// problems found are available with LoadErrors.
+func (w *World) UnmarshalFromMode(dr io.Reader, mode DeserializeMode) error {
 	x := &DeserializedWorld{}
 	md, err := toml.NewDecoder(dr).Decode(x)
 	if err != nil {
 		return fmt.Errorf("failed to decode toml world data: %w", err)
 	}
-	return w.deserializeData(md, x)
+	return w.deserializeData(md, x, mode)
 }
 
+// UnmarshalFromMeta loads the world data from a TOML primitive (e.g. a table of
+// a larger document). Invalid data is skipped (see LoadErrors).
 func (w *World) UnmarshalFromMeta(md toml.MetaData, prim toml.Primitive) error {
+	return w.UnmarshalFromMetaMode(md, prim, DeserializeLenient)
+}
+
+// UnmarshalFromMetaMode loads the world data from a TOML primitive with the
+// deserialization mode (see UnmarshalFromMode).
+func (w *World) UnmarshalFromMetaMode(md toml.MetaData, prim toml.Primitive, mode DeserializeMode) error {
 	x := &DeserializedWorld{}
 	err := md.PrimitiveDecode(prim, x)
 	if err != nil {
 		return fmt.Errorf("failed to decode toml world data: %w", err)
 	}
-	return w.deserializeData(md, x)
+	return w.deserializeData(md, x, mode)
 }
 
+// GetGenericComponent returns the component store by its registry name
+// (Pkg()). If the store doesn't exist yet, it is created if the component type
+// was registered with RegisterComponent. Otherwise, nil is returned.
 func (w *World) GetGenericComponent(registryName string) IComponentStore {
-	return w.components[registryName]
+	if c, ok := w.components[registryName]; ok {
+		return c
+	}
+	globalComponents.lock.RLock()
+	factory := globalComponents.factories[registryName]
+	globalComponents.lock.RUnlock()
+	if factory == nil {
+		return nil
+	}
+	return factory(w)
 }
 
 func (w *World) addSystem(sys ISystem) int {
@@ -188,50 +403,239 @@ func (w *World) addSystem(sys ISystem) int {
 	return w.sysid
 }
 
-func (w *World) deserializeData(md toml.MetaData, dw *DeserializedWorld) error {
+func (w *World) deserializeData(md toml.MetaData, dw *DeserializedWorld, mode DeserializeMode) error {
 	w.isloading = true
-	decoderMutex.Lock()
-	defer decoderMutex.Unlock()
-	setDecoderWorld(w)
-	defer setDecoderWorld(nil)
-	w.enabled = dw.Enabled
+	defer func() {
+		w.isloading = false
+	}()
+	defer w.lockCodec()()
 	compoSmap := dw.ComponentIndex.ToMap()
 	compoImap := make(map[int]string)
 	for k, v := range compoSmap {
 		compoImap[v] = k
 	}
+	compoVersions := make(map[int]int)
+	for _, v := range dw.ComponentIndex {
+		compoVersions[v.Index] = v.Version
+	}
 	compos := make(map[int]IComponentStore)
 	// the components need to be registered beforehand
 	for i, v := range compoImap {
 		compos[i] = w.GetGenericComponent(v)
 	}
+
+	type decodedComponent struct {
+		store IComponentStore
+		data  interface{}
+	}
+	type decodedEntity struct {
+		id         uuid.UUID
+		name       string
+		components []decodedComponent
+	}
+	w.loadErrors = nil
+	errs := make(DeserializeErrors, 0)
+	report := func(derr *DeserializeError) {
+		errs = append(errs, derr)
+		if mode == DeserializeLenient && SerializerLogger != nil {
+			SerializerLogger.Printf("%v", derr)
+		}
+	}
+	type decodedRelation struct {
+		store          IRelationStore
+		source, target uuid.UUID
+		data           interface{}
+	}
+	// entities referenced by components are created while decoding, so they
+	// are discarded if the load is aborted
+	lastEntity := w.beginDecoding()
+	decoded := make([]decodedEntity, 0, len(dw.Entities))
+	loaded := make(map[uuid.UUID]struct{}, len(dw.Entities))
+	for _, ent := range dw.Entities {
+		loaded[ent.UUID] = struct{}{}
+	}
+	uuids := make(map[uuid.UUID]struct{})
+	names := make(map[string]struct{})
 	for _, ent := range dw.Entities {
-		e := w.getEntityByUUID(ent.UUID)
+		if _, ok := uuids[ent.UUID]; ok {
+			report(&DeserializeError{
+				EntityUUID: ent.UUID,
+				Err:        ErrDuplicateUUID,
+			})
+		}
+		uuids[ent.UUID] = struct{}{}
+		dent := decodedEntity{
+			id:         ent.UUID,
+			name:       ent.Name,
+			components: make([]decodedComponent, 0, len(ent.Components)),
+		}
+		if ent.Name != "" && w.names.unique {
+			if _, ok := names[ent.Name]; ok || w.nameTakenAfterLoad(ent.Name, loaded) {
+				report(&DeserializeError{
+					EntityUUID: ent.UUID,
+					Err:        fmt.Errorf("%w: %q", ErrNameTaken, ent.Name),
+				})
+				dent.name = ""
+			}
+			names[ent.Name] = struct{}{}
+		}
 		for _, c := range ent.Components {
+			name, ok := compoImap[c.CI]
+			if !ok {
+				name = fmt.Sprintf("[%d]", c.CI)
+			}
 			if compos[c.CI] == nil {
-				if SerializerLogger != nil {
-					SerializerLogger.Printf("component [%d] %s not registered", c.CI, compoImap[c.CI])
-				}
-			} else {
-				compos[c.CI].dataImport(e, c.Data, md)
+				report(&DeserializeError{
+					EntityUUID: ent.UUID,
+					Component:  name,
+					Err:        ErrUnknownComponent,
+				})
+				continue
 			}
+			d, err := compos[c.CI].dataDecode(c.Data, md, compoVersions[c.CI])
+			if err != nil {
+				report(&DeserializeError{
+					EntityUUID: ent.UUID,
+					Component:  name,
+					Err:        err,
+				})
+				continue
+			}
+			dent.components = append(dent.components, decodedComponent{
+				store: compos[c.CI],
+				data:  d,
+			})
+		}
+		decoded = append(decoded, dent)
+	}
+	relations := make([]decodedRelation, 0, len(dw.Relations))
+	for _, r := range dw.Relations {
+		store := w.GetGenericRelation(r.Relation)
+		if store == nil {
+			report(&DeserializeError{
+				EntityUUID: r.Source,
+				Component:  r.Relation,
+				Err:        ErrUnknownRelation,
+			})
+			continue
+		}
+		d, err := store.dataDecode(r.Data, md)
+		if err != nil {
+			report(&DeserializeError{
+				EntityUUID: r.Source,
+				Component:  r.Relation,
+				Err:        err,
+			})
+			continue
+		}
+		relations = append(relations, decodedRelation{
+			store:  store,
+			source: r.Source,
+			target: r.Target,
+			data:   d,
+		})
+	}
+	if len(errs) > 0 {
+		w.loadErrors = errs
+	}
+	if mode == DeserializeStrict && len(errs) > 0 {
+		w.endDecoding(lastEntity, true)
+		return errs
+	}
+	w.endDecoding(lastEntity, false)
+	w.enabled = dw.Enabled
+	for _, dent := range decoded {
+		e := w.getEntityByUUID(dent.id)
+		// the names of the loaded entities are replaced (or removed)
+		w.names.set(e, dent.name)
+		for _, c := range dent.components {
+			c.store.dataReplace(e, c.data)
 		}
 	}
-	w.isloading = false
+	for _, r := range relations {
+		r.store.dataRelate(w.getEntityByUUID(r.source), w.getEntityByUUID(r.target), r.data)
+	}
 	return nil
 }
 
+// nameTakenAfterLoad returns true if an entity that is not loaded (its UUID is
+// not in loaded) has the name. The names of the loaded entities are
+// replaced, so they don't count.
+func (w *World) nameTakenAfterLoad(name string, loaded map[uuid.UUID]struct{}) bool {
+	for _, e := range w.names.byName[name] {
+		id, ok := w.entityIDs[e]
+		if !ok {
+			return true
+		}
+		if _, ok := loaded[id]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadErrors returns the problems found by the last load (see
+// UnmarshalFromMode), or nil if there were none.
+func (w *World) LoadErrors() DeserializeErrors {
+	return w.loadErrors
+}
+
+// beginDecoding starts decoding data that may create the entities it
+// references (see getEntityByUUID). It returns the last entity created
+// before decoding, to be passed to endDecoding.
+func (w *World) beginDecoding() Entity {
+	w.decoding = true
+	return w.lastEntity
+}
+
+// endDecoding ends decoding (see beginDecoding). If the decoded data is
+// discarded, the entities created while decoding are removed; their IDs are
+// not reused. Otherwise, their spawns are recorded and they are marked as
+// dirty in the relevances.
+func (w *World) endDecoding(last Entity, discard bool) {
+	w.decoding = false
+	i, _ := getEntityIndex(w.entities, last+1)
+	created := w.entities[i:]
+	if discard {
+		for _, e := range created {
+			if id, ok := w.entityIDs[e]; ok {
+				delete(w.entityUUIDs, id)
+				delete(w.entityIDs, e)
+			}
+		}
+		w.entities = w.entities[:i]
+		return
+	}
+	w.invalidateRelevance(created...)
+	if h := w.recording(); h != nil {
+		for _, e := range created {
+			h.recordSpawn(e)
+		}
+	}
+}
+
 func (w *World) serializeData(me Encoder) error {
 	encoderMutex.Lock()
 	defer encoderMutex.Unlock()
 	setEncoderWorld(w)
 	defer setEncoderWorld(nil)
+	return me.Encode(w.serializedWorld())
+}
+
+// serializedWorld must be called with the encoder world set
+func (w *World) serializedWorld() SerializedWorld {
 	sw := SerializedWorld{
 		Entities: make([]SerializedEntity, 0, len(w.entities)),
 		Enabled:  w.enabled,
 	}
 	compIndex := make(map[string]int)
-	entt := make(map[Entity]*SerializedEntity)
+	entt := make(map[Entity]*SerializedEntity, len(w.entities))
+	for _, e := range w.entities {
+		entt[e] = &SerializedEntity{
+			UUID:       w.EntityUUID(e),
+			Components: make([]interface{}, 0),
+		}
+	}
 
 	type ctuple struct {
 		Name  string
@@ -250,20 +654,19 @@ func (w *World) serializeData(me Encoder) error {
 		ci := indexm + 1
 		compIndex[ctuplev.Name] = ci
 		c.dataExtract(func(e Entity, d interface{}) {
-			ent := entt[e]
-			if ent == nil {
-				ent = &SerializedEntity{
-					UUID:       w.EntityUUID(e),
-					Components: make([]interface{}, 0),
-				}
+			if ent := entt[e]; ent != nil {
+				ent.Components = append(ent.Components, SerializedComponentData{
+					CI:   ci,
+					Data: d,
+				})
 			}
-			ent.Components = append(ent.Components, SerializedComponentData{
-				CI:   ci,
-				Data: d,
-			})
-			entt[e] = ent
 		})
 	}
+	for e, name := range w.names.names {
+		if ent := entt[e]; ent != nil {
+			ent.Name = name
+		}
+	}
 	stb := make([]Sortable[Entity, *SerializedEntity], 0, len(entt))
 	for eid, ent := range entt {
 		stb = append(stb, Sortable[Entity, *SerializedEntity]{
@@ -278,7 +681,8 @@ func (w *World) serializeData(me Encoder) error {
 		sw.Entities = append(sw.Entities, *s.Data)
 	}
 	sw.ComponentIndex = componentIndexFromMap(compIndex)
-	return me.Encode(sw)
+	sw.Relations = w.serializedRelations()
+	return sw
 }
 
 // NewWorld creates a new world. A world is not thread safe .I t shouldn't be
@@ -286,11 +690,7 @@ func (w *World) serializeData(me Encoder) error {
 // world. To create a new world without any systems, use NewEmptyWorld()
 func NewWorld() *World {
 	w := newWorld()
-	globalSystems.lock.Lock()
-	defer globalSystems.lock.Unlock()
-	for _, sf := range globalSystems.sysFactory {
-		sf(w)
-	}
+	addGlobalSystems(w)
 	return w
 }
 
@@ -311,11 +711,13 @@ func newWorld() *World {
 		lastEntity:   0,
 		entities:     make([]Entity, 0, 1024),
 		entityIDs:    make(map[Entity]uuid.UUID),
+		names:        newEntityNames(),
 		entityUUIDs:  make(map[uuid.UUID]Entity),
 		components:   make(map[string]IComponentStore),
 		systems:      make([]ISystem, 0, 32),
 		sysMap:       make(map[int]ISystem),
 		enabled:      true,
 		eventManager: newEventManager(),
+		eventQueues:  make(map[string]eventQueueSwapper),
 	}
 }
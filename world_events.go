@@ -1,61 +1,407 @@
 package ecs
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 type Event struct {
-	Name string
-	Data interface{}
+	Name   string
+	Data   interface{}
+	Entity Entity // the target entity (zero if the event is not targeted)
+
+	dispatch *eventDispatch
+}
+
+// StopPropagation prevents the event from reaching the remaining listeners.
+func (e Event) StopPropagation() {
+	if e.dispatch != nil {
+		e.dispatch.stopped = true
+	}
 }
 
 type ListenerID struct {
-	Name string
-	ID   int
+	Name   string
+	ID     int
+	Entity Entity // set if the listener is bound to an entity
+}
+
+type eventDispatch struct {
+	stopped bool
 }
 
+type eventListener struct {
+	id       int
+	priority int
+	fn       func(e Event)
+	removed  bool
+	filter   IComponentStore // component observers only run if the target has it
+}
+
+// eventManager stores the listeners by event name. The listener slices are
+// never modified in place (copy on write), so FireEvent can iterate them
+// without holding the lock. This allows listeners to add or remove listeners
+// and to fire other events.
 type eventManager struct {
-	l      sync.Mutex
-	lastid int
-	evts   map[string]map[int]func(e Event)
+	l          sync.Mutex
+	lastid     int
+	evts       map[string][]*eventListener
+	entityEvts map[Entity]map[string][]*eventListener
+	compEvts   map[string][]*eventListener
+	recorder   *EventRecorder
+	depth      int // number of events being dispatched and systems running
 }
 
 func newEventManager() *eventManager {
 	return &eventManager{
-		evts: make(map[string]map[int]func(e Event)),
+		evts:       make(map[string][]*eventListener),
+		entityEvts: make(map[Entity]map[string][]*eventListener),
+		compEvts:   make(map[string][]*eventListener),
 	}
 }
 
+// OnEvent registers a listener of the event name. Listeners run in the order
+// they were registered (see OnEventPriority).
 func (w *World) OnEvent(eventName string, fn func(e Event)) ListenerID {
+	return w.OnEventPriority(eventName, 0, fn)
+}
+
+// OnEventPriority registers a listener of the event name with a priority.
+// Listeners with a lower priority run first. Listeners with the same priority
+// run in the order they were registered.
+func (w *World) OnEventPriority(eventName string, priority int, fn func(e Event)) ListenerID {
 	w.eventManager.l.Lock()
 	defer w.eventManager.l.Unlock()
-	if w.eventManager.evts[eventName] == nil {
-		w.eventManager.evts[eventName] = make(map[int]func(e Event))
-	}
-	w.eventManager.lastid++
-	id := w.eventManager.lastid
-	w.eventManager.evts[eventName][id] = fn
+	l := w.eventManager.newListener(priority, fn)
+	w.eventManager.evts[eventName] = insertListener(w.eventManager.evts[eventName], l)
 	return ListenerID{
 		Name: eventName,
-		ID:   id,
+		ID:   l.id,
 	}
 }
 
-func (w *World) RemoveListener(id ListenerID) {
+// OnEntityEvent registers a listener of the event name targeted at the entity
+// e (see FireEntityEvent). The listener is removed when the entity is removed
+// from the world. If e is not in the world, the listener is not registered
+// and the returned ID is a no-op.
+func (w *World) OnEntityEvent(e Entity, eventName string, fn func(e Event)) ListenerID {
+	if !w.hasEntity(e) {
+		return ListenerID{
+			Name: eventName,
+		}
+	}
 	w.eventManager.l.Lock()
 	defer w.eventManager.l.Unlock()
-	if w.eventManager.evts[id.Name] != nil {
-		delete(w.eventManager.evts[id.Name], id.ID)
+	l := w.eventManager.newListener(0, fn)
+	evts := w.eventManager.entityEvts[e]
+	if evts == nil {
+		evts = make(map[string][]*eventListener)
+		w.eventManager.entityEvts[e] = evts
+	}
+	evts[eventName] = insertListener(evts[eventName], l)
+	return ListenerID{
+		Name:   eventName,
+		ID:     l.id,
+		Entity: e,
 	}
 }
 
-func (w *World) FireEvent(eventName string, data interface{}) {
+// RemoveListener removes a listener. It is safe to call it from inside a
+// listener (including the listener being removed).
+func (w *World) RemoveListener(id ListenerID) {
 	w.eventManager.l.Lock()
 	defer w.eventManager.l.Unlock()
-	if w.eventManager.evts[eventName] != nil {
-		for _, fn := range w.eventManager.evts[eventName] {
-			fn(Event{
-				Name: eventName,
-				Data: data,
-			})
+	m := w.eventManager
+	if id.Entity != 0 {
+		if evts := m.entityEvts[id.Entity]; evts != nil {
+			if next, ok := removeListener(evts[id.Name], id.ID); ok {
+				setListeners(evts, id.Name, next)
+				if len(evts) == 0 {
+					delete(m.entityEvts, id.Entity)
+				}
+				return
+			}
+		}
+	}
+	if next, ok := removeListener(m.evts[id.Name], id.ID); ok {
+		setListeners(m.evts, id.Name, next)
+		return
+	}
+	if next, ok := removeListener(m.compEvts[id.Name], id.ID); ok {
+		setListeners(m.compEvts, id.Name, next)
+	}
+}
+
+// ListenerInfo describes an event listener (see World.Listeners).
+type ListenerInfo struct {
+	ListenerID
+	Priority int
+	Observer bool // component observer (see OnComponentEvent)
+}
+
+// Listeners returns all the event listeners of the world, sorted by event
+// name and registration.
+func (w *World) Listeners() []ListenerInfo {
+	m := w.eventManager
+	m.l.Lock()
+	defer m.l.Unlock()
+	result := make([]ListenerInfo, 0)
+	add := func(evts map[string][]*eventListener, e Entity, observer bool) {
+		for name, listeners := range evts {
+			for _, l := range listeners {
+				result = append(result, ListenerInfo{
+					ListenerID: ListenerID{Name: name, ID: l.id, Entity: e},
+					Priority:   l.priority,
+					Observer:   observer,
+				})
+			}
+		}
+	}
+	add(m.evts, 0, false)
+	add(m.compEvts, 0, true)
+	for e, evts := range m.entityEvts {
+		add(evts, e, false)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name == result[j].Name {
+			return result[i].ID < result[j].ID
 		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// FireEvent runs (synchronously) all the listeners of the event name, until a
+// listener stops the propagation of the event. Listeners may fire events, add
+// listeners or remove listeners. Listeners added while the event is being
+// dispatched will only receive the next events.
+func (w *World) FireEvent(eventName string, data interface{}) {
+	m := w.eventManager
+	m.l.Lock()
+	listeners := m.evts[eventName]
+	recorder := m.topRecorder()
+	m.l.Unlock()
+	if recorder != nil {
+		recorder.record(eventName, 0, data)
+	}
+	defer m.enter()()
+	m.dispatch(listeners, Event{
+		Name:     eventName,
+		Data:     data,
+		dispatch: &eventDispatch{},
+	})
+}
+
+// FireEntityEvent runs (synchronously) the listeners of the event name that
+// are targeted at the entity e, the component observers (see
+// OnComponentEvent) of the components of e and the global listeners of the
+// event name. The listeners run ordered by priority and registration.
+func (w *World) FireEntityEvent(e Entity, eventName string, data interface{}) {
+	m := w.eventManager
+	m.l.Lock()
+	listeners := make([]*eventListener, 0)
+	if evts := m.entityEvts[e]; evts != nil {
+		listeners = append(listeners, evts[eventName]...)
 	}
+	observers := m.compEvts[eventName]
+	listeners = append(listeners, m.evts[eventName]...)
+	recorder := m.topRecorder()
+	m.l.Unlock()
+	if recorder != nil {
+		recorder.record(eventName, e, data)
+	}
+	defer m.enter()()
+	for _, l := range observers {
+		if l.filter.Contains(e) {
+			listeners = append(listeners, l)
+		}
+	}
+	sort.SliceStable(listeners, func(i, j int) bool {
+		if listeners[i].priority == listeners[j].priority {
+			return listeners[i].id < listeners[j].id
+		}
+		return listeners[i].priority < listeners[j].priority
+	})
+	m.dispatch(listeners, Event{
+		Name:     eventName,
+		Data:     data,
+		Entity:   e,
+		dispatch: &eventDispatch{},
+	})
+}
+
+// topRecorder returns the recorder if no event is being dispatched and no
+// system is running (nested events and events fired by systems are not
+// recorded). It must be called with the lock held.
+func (m *eventManager) topRecorder() *EventRecorder {
+	if m.depth > 0 {
+		return nil
+	}
+	return m.recorder
+}
+
+// enter marks an event as being dispatched (or a system as running) until the
+// returned func is called
+func (m *eventManager) enter() func() {
+	m.l.Lock()
+	m.depth++
+	m.l.Unlock()
+	return func() {
+		m.l.Lock()
+		m.depth--
+		m.l.Unlock()
+	}
+}
+
+func (m *eventManager) dispatch(listeners []*eventListener, e Event) {
+	for _, l := range listeners {
+		m.l.Lock()
+		removed := l.removed
+		m.l.Unlock()
+		if removed {
+			continue
+		}
+		l.fn(e)
+		if e.dispatch.stopped {
+			return
+		}
+	}
+}
+
+// newListener must be called with the lock held
+func (m *eventManager) newListener(priority int, fn func(e Event)) *eventListener {
+	m.lastid++
+	return &eventListener{
+		id:       m.lastid,
+		priority: priority,
+		fn:       fn,
+	}
+}
+
+func (m *eventManager) addComponentObserver(eventName string, filter IComponentStore, fn func(e Event)) ListenerID {
+	m.l.Lock()
+	defer m.l.Unlock()
+	l := m.newListener(0, fn)
+	l.filter = filter
+	m.compEvts[eventName] = insertListener(m.compEvts[eventName], l)
+	return ListenerID{
+		Name: eventName,
+		ID:   l.id,
+	}
+}
+
+// removeEntity drops all the listeners bound to the entity e
+func (m *eventManager) removeEntity(e Entity) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	for _, listeners := range m.entityEvts[e] {
+		for _, l := range listeners {
+			l.removed = true
+		}
+	}
+	delete(m.entityEvts, e)
+}
+
+// insertListener returns a copy of cur with l inserted after all the
+// listeners with the same or lower priority.
+func insertListener(cur []*eventListener, l *eventListener) []*eventListener {
+	index := len(cur)
+	for i, v := range cur {
+		if v.priority > l.priority {
+			index = i
+			break
+		}
+	}
+	next := make([]*eventListener, 0, len(cur)+1)
+	next = append(next, cur[:index]...)
+	next = append(next, l)
+	next = append(next, cur[index:]...)
+	return next
+}
+
+// removeListener returns a copy of cur without the listener id.
+func removeListener(cur []*eventListener, id int) ([]*eventListener, bool) {
+	for i, l := range cur {
+		if l.id == id {
+			l.removed = true
+			next := make([]*eventListener, 0, len(cur)-1)
+			next = append(next, cur[:i]...)
+			next = append(next, cur[i+1:]...)
+			return next, true
+		}
+	}
+	return cur, false
+}
+
+func setListeners(m map[string][]*eventListener, name string, listeners []*eventListener) {
+	if len(listeners) == 0 {
+		delete(m, name)
+		return
+	}
+	m[name] = listeners
+}
+
+// EventType is a data type that has a Pkg() function. It identifies a typed
+// event (see OnEvent and Emit) the same way ComponentType identifies a
+// component.
+type EventType interface {
+	Pkg() string
+}
+
+// OnEvent registers a listener of the event type T. To unsubscribe, call
+// World.RemoveListener with the returned ListenerID.
+func OnEvent[T EventType](w *World, fn func(v T)) ListenerID {
+	var zv T
+	return w.OnEvent(zv.Pkg(), func(e Event) {
+		if v, ok := e.Data.(T); ok {
+			fn(v)
+		}
+	})
+}
+
+// OnEventPriority registers a listener of the event type T with a priority
+// (see World.OnEventPriority).
+func OnEventPriority[T EventType](w *World, priority int, fn func(v T)) ListenerID {
+	var zv T
+	return w.OnEventPriority(zv.Pkg(), priority, func(e Event) {
+		if v, ok := e.Data.(T); ok {
+			fn(v)
+		}
+	})
+}
+
+// Emit fires the event v to all the listeners of the event type T.
+func Emit[T EventType](w *World, v T) {
+	var zv T
+	w.FireEvent(zv.Pkg(), v)
+}
+
+// OnEntityEvent registers a listener of the event type T targeted at the
+// entity e (see EmitTo). The listener is removed when the entity is removed
+// from the world. If e is not in the world, the listener is not registered.
+func OnEntityEvent[T EventType](w *World, e Entity, fn func(e Entity, v T)) ListenerID {
+	var zv T
+	return w.OnEntityEvent(e, zv.Pkg(), func(ev Event) {
+		if v, ok := ev.Data.(T); ok {
+			fn(ev.Entity, v)
+		}
+	})
+}
+
+// OnComponentEvent registers an observer of the event type T targeted at any
+// entity that has the component C (see EmitTo).
+func OnComponentEvent[C ComponentType, T EventType](w *World, fn func(e Entity, v T)) ListenerID {
+	var zv T
+	return w.eventManager.addComponentObserver(zv.Pkg(), GetComponentStore[C](w), func(ev Event) {
+		if v, ok := ev.Data.(T); ok {
+			fn(ev.Entity, v)
+		}
+	})
+}
+
+// EmitTo fires the event v targeted at the entity e (see
+// World.FireEntityEvent).
+func EmitTo[T EventType](w *World, e Entity, v T) {
+	var zv T
+	w.FireEntityEvent(e, zv.Pkg(), v)
 }
@@ -0,0 +1,212 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type damageEvent struct {
+	Amount int
+}
+
+func (damageEvent) Pkg() string {
+	return "test.damageEvent"
+}
+
+type healEvent struct {
+	Amount int
+}
+
+func (healEvent) Pkg() string {
+	return "test.healEvent"
+}
+
+func TestTypedEvents(t *testing.T) {
+	w := NewWorld()
+	total := 0
+	id := OnEvent(w, func(v damageEvent) {
+		total += v.Amount
+	})
+	OnEvent(w, func(v healEvent) {
+		total -= v.Amount
+	})
+	Emit(w, damageEvent{Amount: 10})
+	Emit(w, healEvent{Amount: 3})
+	assert.Equal(t, 7, total)
+	w.RemoveListener(id)
+	Emit(w, damageEvent{Amount: 10})
+	assert.Equal(t, 7, total)
+}
+
+func TestEventQueue(t *testing.T) {
+	w := NewWorld()
+	reads := make([]int, 0)
+	readsBefore := make([]int, 0)
+	var before, after *EventReader[damageEvent]
+	sysBefore := NewSystem[BenchPos3](0, w)
+	sysBefore.Run = func(view *View[BenchPos3]) {
+		if before == nil {
+			before = GetEventQueue[damageEvent](w).NewReader()
+		}
+		before.Read(func(v damageEvent) {
+			readsBefore = append(readsBefore, v.Amount)
+		})
+	}
+	writer := NewSystem[BenchPos3](1, w)
+	tick := 0
+	writer.Run = func(view *View[BenchPos3]) {
+		tick++
+		WriteEvent(w, damageEvent{Amount: tick * 10})
+		WriteEvent(w, damageEvent{Amount: tick*10 + 1})
+	}
+	sysAfter := NewSystem[BenchPos3](2, w)
+	sysAfter.Run = func(view *View[BenchPos3]) {
+		if after == nil {
+			after = GetEventQueue[damageEvent](w).NewReader()
+		}
+		after.Read(func(v damageEvent) {
+			reads = append(reads, v.Amount)
+		})
+	}
+	w.Step()
+	assert.Equal(t, []int{10, 11}, reads)
+	assert.Equal(t, []int{}, readsBefore)
+	w.Step()
+	assert.Equal(t, []int{10, 11, 20, 21}, reads)
+	assert.Equal(t, []int{10, 11}, readsBefore)
+	assert.Equal(t, 4, GetEventQueue[damageEvent](w).Len())
+	w.Step()
+	assert.Equal(t, 4, GetEventQueue[damageEvent](w).Len())
+	all := make([]int, 0)
+	GetEventQueue[damageEvent](w).Each(func(v damageEvent) {
+		all = append(all, v.Amount)
+	})
+	assert.Equal(t, []int{20, 21, 30, 31}, all)
+	assert.Equal(t, []int{10, 11, 20, 21}, readsBefore)
+}
+
+func TestEventQueueStepF(t *testing.T) {
+	w := NewWorld()
+	reads := make([]int, 0)
+	sys := NewSystem[BenchPos3](0, w)
+	sys.SetFlag(2)
+	var r *EventReader[damageEvent]
+	sys.Run = func(view *View[BenchPos3]) {
+		if r == nil {
+			r = GetEventQueue[damageEvent](w).NewReader()
+		}
+		r.Read(func(v damageEvent) {
+			reads = append(reads, v.Amount)
+		})
+	}
+	// fixed steps: the events of a frame are kept until the next frame
+	WriteEvent(w, damageEvent{Amount: 1})
+	w.StepF(2)
+	WriteEvent(w, damageEvent{Amount: 2})
+	w.StepF(2)
+	w.StepF(2)
+	assert.Equal(t, []int{1, 2}, reads)
+	w.UpdateEvents()
+	WriteEvent(w, damageEvent{Amount: 3})
+	w.StepF(2)
+	assert.Equal(t, []int{1, 2, 3}, reads)
+	assert.Equal(t, 3, GetEventQueue[damageEvent](w).Len())
+	w.UpdateEvents()
+	w.UpdateEvents()
+	assert.Equal(t, 0, GetEventQueue[damageEvent](w).Len())
+}
+
+func TestEventDispatchOrder(t *testing.T) {
+	w := NewWorld()
+	calls := make([]string, 0)
+	w.OnEvent("hit", func(e Event) {
+		calls = append(calls, "a")
+	})
+	w.OnEventPriority("hit", -1, func(e Event) {
+		calls = append(calls, "first")
+	})
+	w.OnEvent("hit", func(e Event) {
+		calls = append(calls, "b")
+	})
+	w.OnEventPriority("hit", 10, func(e Event) {
+		calls = append(calls, "last")
+	})
+	for i := 0; i < 10; i++ {
+		calls = calls[:0]
+		w.FireEvent("hit", nil)
+		assert.Equal(t, []string{"first", "a", "b", "last"}, calls)
+	}
+}
+
+func TestEventDispatchReentrant(t *testing.T) {
+	w := NewWorld()
+	calls := make([]string, 0)
+	var once ListenerID
+	once = w.OnEvent("hit", func(e Event) {
+		calls = append(calls, "once")
+		w.RemoveListener(once)
+		// nested events and new listeners don't deadlock
+		w.FireEvent("nested", e.Data)
+		w.OnEvent("hit", func(e Event) {
+			calls = append(calls, "late")
+		})
+	})
+	w.OnEvent("nested", func(e Event) {
+		calls = append(calls, "nested")
+	})
+	w.OnEvent("hit", func(e Event) {
+		calls = append(calls, "stop")
+		e.StopPropagation()
+	})
+	w.OnEvent("hit", func(e Event) {
+		calls = append(calls, "never")
+	})
+	w.FireEvent("hit", nil)
+	assert.Equal(t, []string{"once", "nested", "stop"}, calls)
+	calls = calls[:0]
+	w.FireEvent("hit", nil)
+	assert.Equal(t, []string{"stop"}, calls)
+}
+
+func TestEntityEvents(t *testing.T) {
+	w := NewWorld()
+	e1 := w.NewEntity()
+	e2 := w.NewEntity()
+	Set(w, e2, Position{})
+	hits := make(map[Entity]int)
+	OnEntityEvent(w, e1, func(e Entity, v damageEvent) {
+		hits[e] += v.Amount
+	})
+	OnComponentEvent[Position](w, func(e Entity, v damageEvent) {
+		hits[e] += v.Amount * 100
+	})
+	global := 0
+	OnEvent(w, func(v damageEvent) {
+		global++
+	})
+	EmitTo(w, e1, damageEvent{Amount: 1})
+	EmitTo(w, e2, damageEvent{Amount: 2})
+	assert.Equal(t, map[Entity]int{e1: 1, e2: 200}, hits)
+	assert.Equal(t, 2, global)
+
+	RemoveComponent[Position](w, e2)
+	EmitTo(w, e2, damageEvent{Amount: 2})
+	assert.Equal(t, 200, hits[e2])
+
+	assert.True(t, w.Remove(e1))
+	EmitTo(w, e1, damageEvent{Amount: 1})
+	assert.Equal(t, 1, hits[e1])
+	assert.Equal(t, 0, len(w.eventManager.entityEvts))
+
+	// listeners of the zero entity or of entities not in the world are not
+	// registered
+	for _, e := range []Entity{0, e1, 1000} {
+		id := OnEntityEvent(w, e, func(e Entity, v damageEvent) {
+			t.Fatal("listener of an entity not in the world")
+		})
+		w.RemoveListener(id)
+		EmitTo(w, e, damageEvent{Amount: 1})
+	}
+	assert.Equal(t, 0, len(w.eventManager.entityEvts))
+}
@@ -185,3 +185,142 @@ func TestWorldRemoveEntity(t *testing.T) {
 	assert.False(t, Remove(w, e))
 	assert.False(t, Apply(w, e, func(p *BenchPos3) {}))
 }
+
+func TestWorldRemoveMany(t *testing.T) {
+	w := NewEmptyWorld()
+	ents := w.NewEntities(10)
+	for i, e := range ents {
+		Set(w, e, BenchPos3{X: float64(i)})
+		if i%2 == 0 {
+			Set(w, e, BenchSpeed3{Xs: 1})
+		}
+	}
+	Set(w, ents[4], sparseStatus{})
+	removed := make([]Entity, 0)
+	view := NewView2[BenchPos3, BenchSpeed3](w, nil, func(e Entity) {
+		removed = append(removed, e)
+	})
+	id := w.EntityUUID(ents[2])
+	w.OnEntityEvent(ents[2], "test", func(e Event) {
+		t.Fatal("removed entity got an event")
+	})
+
+	assert.Equal(t, 3, w.RemoveMany([]Entity{ents[4], ents[2], ents[1], ents[4], 1000}))
+	assert.Equal(t, 0, w.RemoveMany([]Entity{ents[1]}))
+	assert.Equal(t, []Entity{ents[2], ents[4]}, removed)
+	assert.Equal(t, 3, view.Len())
+	assert.Equal(t, 7, GetComponentStore[BenchPos3](w).Len())
+	assert.False(t, Contains[sparseStatus](w, ents[4]))
+	assert.True(t, Contains[BenchPos3](w, ents[3]))
+	_, ok := w.EntityByUUID(id)
+	assert.False(t, ok)
+	w.FireEntityEvent(ents[2], "test", nil)
+
+	w.Clear()
+	assert.Equal(t, 0, GetComponentStore[BenchPos3](w).Len())
+	assert.Equal(t, 0, view.Len())
+	assert.Equal(t, 5, len(removed))
+	assert.Greater(t, w.NewEntity(), ents[9])
+}
+
+const invalidWorldText = `enabled = true
+
+[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+
+  [[entities.components]]
+    ci = 1
+    [entities.components.data]
+      X = 1.0
+      Y = 2.0
+      Z = 3.0
+
+  [[entities.components]]
+    ci = 2
+    [entities.components.data]
+      Value = 1
+
+[[entities]]
+  uuid = "6D3F1C5A-2E4B-4C8D-9A1F-3B5E7D9C2A4F"
+
+  [[entities.components]]
+    ci = 1
+    [entities.components.data]
+      X = "one"
+
+[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+
+[[component_index]]
+  Name = "test.BenchPos3"
+  Index = 1
+
+[[component_index]]
+  Name = "test.NotRegistered"
+  Index = 2
+`
+
+func TestUnmarshalStrict(t *testing.T) {
+	w := NewWorld()
+	_ = GetComponentStore[BenchPos3](w)
+	err := w.UnmarshalFromMode(bytes.NewBufferString(invalidWorldText), DeserializeStrict)
+	derrs, ok := err.(DeserializeErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(derrs))
+	assert.ErrorIs(t, err, ErrUnknownComponent)
+	assert.ErrorIs(t, err, ErrDuplicateUUID)
+	assert.Equal(t, "test.NotRegistered", derrs[0].Component)
+	assert.Equal(t, "6d3f1c5a-2e4b-4c8d-9a1f-3b5e7d9c2a4f", derrs[1].EntityUUID.String())
+	assert.Equal(t, "test.BenchPos3", derrs[1].Component)
+	assert.Equal(t, 0, len(w.entities))
+	assert.Equal(t, 0, len(GetComponentStore[BenchPos3](w).data))
+}
+
+func TestUnmarshalStrictReferences(t *testing.T) {
+	w := NewWorld()
+	_ = GetComponentStore[diffTarget](w)
+	h := NewHistory(w)
+	defer h.Close()
+	// the target is created while decoding
+	err := w.UnmarshalFromMode(bytes.NewBufferString(`
+[[entities]]
+  uuid = "1B20DDAE-FE41-41D6-BC7F-EE46C175ED32"
+
+  [[entities.components]]
+    ci = 1
+    [entities.components.data]
+      Target = "6D3F1C5A-2E4B-4C8D-9A1F-3B5E7D9C2A4F"
+
+  [[entities.components]]
+    ci = 2
+
+[[component_index]]
+  Name = "test.diffTarget"
+  Index = 1
+
+[[component_index]]
+  Name = "test.NotRegistered"
+  Index = 2
+`), DeserializeStrict)
+	assert.ErrorIs(t, err, ErrUnknownComponent)
+	assert.Equal(t, 0, len(w.entities))
+	assert.Equal(t, 0, len(w.entityUUIDs))
+	assert.False(t, h.CanUndo())
+	// the IDs of the discarded entities are not reused
+	assert.Equal(t, Entity(2), w.NewEntity())
+}
+
+func TestUnmarshalLenient(t *testing.T) {
+	w := NewWorld()
+	_ = GetComponentStore[BenchPos3](w)
+	err := w.UnmarshalFromMode(bytes.NewBufferString(invalidWorldText), DeserializeLenient)
+	assert.NoError(t, err)
+	derrs := w.LoadErrors()
+	assert.Equal(t, 3, len(derrs))
+	assert.ErrorIs(t, derrs, ErrUnknownComponent)
+	assert.Equal(t, 2, len(w.entities))
+	assert.True(t, Apply(w, w.entities[0], func(p *BenchPos3) {
+		assert.Equal(t, 2.0, p.Y)
+	}))
+	assert.False(t, Contains[BenchPos3](w, w.entities[1]))
+}